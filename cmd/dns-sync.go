@@ -1,9 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/brendandburns/dns-sync/pkg/dns"
 	"github.com/brendandburns/dns-sync/pkg/dns/cloud"
@@ -12,39 +20,413 @@ import (
 )
 
 var (
-	configFile = flag.String("config", "", "Path to config file")
-	cloudDNS   = flag.String("cloud", "", "Which cloud DNS provider to use, currently 'google' or 'azure'")
+	configFile  = flag.String("config", "", "Path to config file")
+	cloudDNS    = flag.String("cloud", "", "Which cloud DNS provider to use, currently 'google' or 'azure'")
+	policy      = flag.String("policy", "sync", "Which changes to make, one of 'sync', 'upsert-only' or 'create-only'")
+	ownerID     = flag.String("owner-id", "", "If set, only modify records owned by this ID, and mark created records as owned by it")
+	force       = flag.Bool("force", false, "If true, make changes and prune zones even if they exceed the --max-* limits")
+	recordsOnly = flag.Bool("records-only", false, "If true, only change the records of an existing zone, found by its DNS name, never the zone itself")
+	pruneZones  = flag.Bool("prune-zones", false, "If true, delete zones that dns-sync created with the same --owner-id but that aren't in the config")
+	yes         = flag.Bool("yes", false, "If true, don't ask for confirmation before deleting zones")
+	savePlan    = flag.String("save-plan", "", "If set, write the plan to this file instead of making any changes, for 'dns-sync apply'")
+	output      = flag.String("output", "table", "Output format, 'table', 'json' or 'yaml'")
+
+	watch         = flag.Bool("watch", false, "If true, keep running, syncing whenever the config changes and every --interval")
+	interval      = flag.Duration("interval", 5*time.Minute, "In --watch mode, how often to sync when the config hasn't changed")
+	pollInterval  = flag.Duration("poll-interval", 5*time.Second, "In --watch mode, how often to check the config for changes")
+	statusAddress = flag.String("status-address", "", "In --watch mode, if set, serve the status of the last sync over HTTP at this address, e.g. ':8080'")
+
+	maxDeletes       = flag.Int("max-deletes", 0, "Abort if more than this many records would be deleted, 0 for no limit")
+	maxDeletePercent = flag.Float64("max-delete-percent", 0, "Abort if more than this percentage of the zone's records would be deleted, 0 for no limit")
+	maxChanges       = flag.Int("max-changes", 0, "Abort if more than this many records would change, 0 for no limit")
+	maxChangePercent = flag.Float64("max-change-percent", 0, "Abort if more than this percentage of the zone's records would change, 0 for no limit")
+
+	maxAttempts = flag.Int("max-attempts", 5, "How many times to try a cloud provider call that fails with a throttling or server error, 1 to disable retries")
+	backoff     = flag.Duration("backoff", time.Second, "How long to wait before the first retry, doubling on every further retry")
+	maxBackoff  = flag.Duration("max-backoff", 30*time.Second, "The longest to wait between retries, unless the provider asks for longer")
+	rateLimit   = flag.Float64("rate-limit", 0, "The maximum number of cloud provider calls per second, 0 for no limit")
+
+	continueOnError = flag.Bool("continue-on-error", false, "If true, keep making changes after one fails, and report every failure at the end")
+	concurrency     = flag.Int("concurrency", 1, "How many changes that don't depend on each other to make at a time")
+
+	deleteNonEmpty = flag.Bool("delete-non-empty", false, "If true, delete-zone and --prune-zones delete zones that still have records, deleting the records first")
 )
 
+// variableFlags collects repeated --set name=value flags.
+type variableFlags map[string]string
+
+func (v variableFlags) String() string {
+	return fmt.Sprintf("%v", map[string]string(v))
+}
+
+func (v variableFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return fmt.Errorf("expected name=value, saw: %s", value)
+	}
+	v[parts[0]] = parts[1]
+	return nil
+}
+
+var variables = variableFlags{}
+
+// zoneFlags collects repeated --prune-zone flags.
+type zoneFlags []string
+
+func (z *zoneFlags) String() string {
+	return strings.Join(*z, ",")
+}
+
+func (z *zoneFlags) Set(value string) error {
+	*z = append(*z, value)
+	return nil
+}
+
+var pruneZoneNames = zoneFlags{}
+
+func init() {
+	flag.Var(variables, "set", "Override a variable declared in the config, as name=value. May be repeated")
+	flag.Var(&pruneZoneNames, "prune-zone", "With --prune-zones, also delete this zone, by name or DNS name, even if dns-sync didn't create it. May be repeated")
+}
+
 func main() {
 	flag.Parse()
 
+	command := "sync"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	switch command {
+	case "schema":
+		runSchema()
+		return
+	case "validate":
+		runValidate()
+		return
+	case "delete-zone":
+		runDeleteZone()
+		return
+	case "apply":
+		runApply()
+		return
+	}
+
+	if len(*configFile) == 0 {
+		log.Fatal("--config is required.")
+	}
+	if command == "sync" && *watch {
+		runWatch()
+		return
+	}
+	config, err := dns.LoadConfig(*configFile, variables)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	glog.V(4).Infof("LoadedConfig: %v\n", config)
+
+	switch command {
+	case "sync":
+		runSync(*config)
+	case "check":
+		runCheck(*config)
+	default:
+		log.Fatalf("Unknown command: %s, expected 'sync', 'check', 'apply', 'validate', 'schema' or 'delete-zone'", command)
+	}
+}
+
+func runSchema() {
+	data, err := dns.SchemaJSON()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	os.Stdout.Write(data)
+}
+
+// validationReport is the machine readable output of the validate command.
+type validationReport struct {
+	Valid    bool          `json:"valid"`
+	Problems []dns.Problem `json:"problems"`
+}
+
+func runValidate() {
 	if len(*configFile) == 0 {
 		log.Fatal("--config is required.")
 	}
-	config := dns.Config{}
-	data, err := ioutil.ReadFile(*configFile)
+	problems, err := dns.ValidateFile(*configFile, variables)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	printOutput(validationReport{Valid: len(problems) == 0, Problems: problems}, func() {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) == 0 {
+			log.Println("Config is valid.")
+		}
+	})
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printOutput prints value in the --output format, calling table for the
+// human readable format.
+func printOutput(value interface{}, table func()) {
+	switch *output {
+	case "table":
+		table()
+		return
+	case "json", "yaml":
+	default:
+		log.Fatalf("Unknown output format: %s, expected 'table', 'json' or 'yaml'", *output)
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
 		log.Fatal(err.Error())
 	}
+	if *output == "yaml" {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			log.Fatal(err.Error())
+		}
+		os.Stdout.Write(data)
+		return
+	}
+	fmt.Println(string(data))
+}
 
-	glog.V(4).Infof("LoadedConfig: %v\n", config)
+// printResult prints the result of a sync, even if it failed part way.
+func printResult(result *dns.Result) {
+	if result == nil {
+		return
+	}
+	printOutput(result, func() {
+		fmt.Println(result)
+	})
+}
 
+func newService() dns.Service {
 	var svc dns.Service
-	if len(*cloudDNS) == 0 || *cloudDNS == "google" {
+	var err error
+	switch *cloudDNS {
+	case "", "google":
 		svc, err = cloud.NewGoogleCloudDNSService()
-	} else if *cloudDNS == "azure" {
+	case "azure":
 		svc, err = cloud.NewAzureDNSService()
+	default:
+		log.Fatalf("Unknown cloud: %s, expected 'google' or 'azure'", *cloudDNS)
 	}
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	if *maxAttempts < 1 {
+		log.Fatal("--max-attempts must be at least 1.")
+	}
+	return &dns.RetryingService{
+		Service:        svc,
+		MaxAttempts:    *maxAttempts,
+		InitialBackoff: *backoff,
+		MaxBackoff:     *maxBackoff,
+		RateLimit:      *rateLimit,
+	}
+}
 
-	if err := dns.Sync(svc, config.Zone, config.Records); err != nil {
+// confirm asks the user to type 'yes', unless --yes was given.
+func confirm(prompt string) bool {
+	if *yes {
+		return true
+	}
+	fmt.Printf("%s Type 'yes' to continue: ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+func runDeleteZone() {
+	if flag.NArg() != 2 {
+		log.Fatal("Usage: dns-sync [flags] delete-zone <zone name or DNS name>")
+	}
+	svc := newService()
+	zone, err := dns.FindZone(svc, flag.Arg(1))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if zone == nil {
+		log.Fatalf("Zone %s doesn't exist.", flag.Arg(1))
+	}
+	if !confirm(fmt.Sprintf("Delete zone %s (%s)?", zone.Name, zone.DNSName)) {
+		log.Fatal("Not confirmed, no changes made.")
+	}
+	if err := dns.DeleteZone(svc, *zone, *deleteNonEmpty); err != nil {
+		log.Fatal(err.Error())
+	}
+	log.Printf("Deleted zone %s.", zone.Name)
+}
+
+// pruneOtherZones deletes the zones in the provider that dns-sync created,
+// or that are listed with --prune-zone, but that aren't in the config, after
+// confirmation.
+func pruneOtherZones(svc dns.Service, config dns.Config) {
+	options := syncOptions()
+	zones, err := dns.ZonesToPrune(svc, []dns.Zone{config.Zone}, dns.PruneOptions{
+		OwnerID: options.OwnerID,
+		Zones:   pruneZoneNames,
+		Limits:  options.Limits,
+		Force:   options.Force,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if len(zones) == 0 {
+		return
+	}
+	names := make([]string, len(zones))
+	for ix, zone := range zones {
+		names[ix] = fmt.Sprintf("%s (%s)", zone.Name, zone.DNSName)
+	}
+	fmt.Printf("Zones not in the config: %s\n", strings.Join(names, ", "))
+	if !confirm(fmt.Sprintf("Delete %d zones?", len(zones))) {
+		log.Fatal("Not confirmed, no zones deleted.")
+	}
+	for _, zone := range zones {
+		if err := dns.DeleteZone(svc, zone, *deleteNonEmpty); err != nil {
+			log.Fatal(err.Error())
+		}
+		log.Printf("Deleted zone %s.", zone.Name)
+	}
+}
+
+// syncOptions returns the sync options set by flags.
+func syncOptions() dns.Options {
+	syncPolicy, err := dns.ParsePolicy(*policy)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return dns.Options{
+		Policy:  syncPolicy,
+		OwnerID: *ownerID,
+		Limits: dns.Limits{
+			MaxDeletes:       *maxDeletes,
+			MaxDeletePercent: *maxDeletePercent,
+			MaxChanges:       *maxChanges,
+			MaxChangePercent: *maxChangePercent,
+		},
+		Force:           *force,
+		RecordsOnly:     *recordsOnly,
+		ContinueOnError: *continueOnError,
+		Concurrency:     *concurrency,
+	}
+}
+
+func runWatch() {
+	if *pruneZones {
+		log.Fatal("--watch and --prune-zones can't be combined.")
+	}
+	daemon := &dns.Daemon{
+		ConfigFile:    *configFile,
+		Overrides:     variables,
+		Service:       newService(),
+		Options:       syncOptions(),
+		Interval:      *interval,
+		PollInterval:  *pollInterval,
+		StatusAddress: *statusAddress,
+	}
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	if err := daemon.Run(stop); err != nil {
 		log.Fatal(err.Error())
 	}
+}
+
+func runApply() {
+	if flag.NArg() != 2 {
+		log.Fatal("Usage: dns-sync [flags] apply <plan file>")
+	}
+	data, err := ioutil.ReadFile(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	plan := &dns.Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		log.Fatalf("%s: %v", flag.Arg(1), err)
+	}
+	result, err := dns.ApplyPlan(newService(), plan, syncOptions())
+	printResult(result)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	log.Println("Synchronized.")
+}
+
+// checkReport is the machine readable output of the check command.
+type checkReport struct {
+	InSync bool     `json:"inSync"`
+	Drift  []string `json:"drift"`
+}
+
+// runCheck exits 0 if the zone matches the config, 2 if it has drifted and 1
+// on errors.
+func runCheck(config dns.Config) {
+	options := syncOptions()
+	options.Ignore = config.Ignore
+	plan, err := dns.Check(newService(), config.Zone, config.Records, options)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	drift := plan.Drift()
+	printOutput(checkReport{InSync: len(drift) == 0, Drift: drift}, func() {
+		for _, line := range drift {
+			fmt.Println(line)
+		}
+	})
+	if len(drift) > 0 {
+		log.Printf("Drift detected, %d changes needed.", len(drift))
+		os.Exit(2)
+	}
+	log.Println("In sync.")
+}
+
+func runSync(config dns.Config) {
+	if *recordsOnly && *pruneZones {
+		log.Fatal("--records-only and --prune-zones can't be combined.")
+	}
+	if len(pruneZoneNames) > 0 && !*pruneZones {
+		log.Fatal("--prune-zone requires --prune-zones.")
+	}
+	svc := newService()
+	options := syncOptions()
+	options.Ignore = config.Ignore
+	if len(*savePlan) > 0 {
+		plan, err := dns.PlanSync(svc, config.Zone, config.Records, options)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		if plan.CreateZone {
+			log.Fatalf("Zone %s doesn't exist, create it first with sync, then save a plan.", config.Zone.Name)
+		}
+		fmt.Println(plan)
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		if err := ioutil.WriteFile(*savePlan, append(data, '\n'), 0644); err != nil {
+			log.Fatal(err.Error())
+		}
+		log.Printf("Saved plan to %s, apply it with 'dns-sync apply %s'.", *savePlan, *savePlan)
+		return
+	}
+	result, err := dns.Sync(svc, config.Zone, config.Records, options)
+	printResult(result)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if *pruneZones {
+		pruneOtherZones(svc, config)
+	}
 	log.Println("Synchronized.")
 }
@@ -0,0 +1,38 @@
+package dns
+
+// isApexNS returns true for the NS record set at the zone apex, which holds
+// the nameservers the provider assigned to the zone.
+func isApexNS(zone Zone, record Record) bool {
+	return record.Type() == "NS" && canonicalName(record.RecordName()) == canonicalName(zone.DNSName)
+}
+
+// withZoneNameservers adds the zone's nameservers to an apex NS record in
+// records, so that a config can add entries to the apex NS record set but
+// never remove the nameservers the zone is served from.
+func withZoneNameservers(zone Zone, records []Record, nameservers []string) []Record {
+	if len(nameservers) == 0 {
+		return records
+	}
+	result := make([]Record, len(records))
+	for ix, record := range records {
+		result[ix] = record
+		ns, ok := record.(NSRecord)
+		if !ok || !isApexNS(zone, ns) {
+			continue
+		}
+		merged := append([]string{}, nameservers...)
+		have := map[string]bool{}
+		for _, nameserver := range nameservers {
+			have[canonicalName(nameserver)] = true
+		}
+		for _, nameserver := range ns.Nameservers {
+			if !have[canonicalName(nameserver)] {
+				have[canonicalName(nameserver)] = true
+				merged = append(merged, nameserver)
+			}
+		}
+		ns.Nameservers = merged
+		result[ix] = ns
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestSyncApex(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	providerNS := NSRecord{
+		BaseRecord:  BaseRecord{Name: "example.com.", TTL: 21600},
+		Nameservers: []string{"ns1.provider.net.", "ns2.provider.net."},
+	}
+	txt := TXTRecord{
+		BaseRecord: BaseRecord{Name: "example.com.", TTL: 300},
+		Text:       []string{"v=spf1 -all"},
+	}
+	address := AddressRecord{
+		BaseRecord: BaseRecord{Name: "example.com.", TTL: 300},
+		Addresses:  []string{"1.2.3.4"},
+	}
+	svc.ZoneMap = map[string]Zone{
+		"test": Zone{Name: "test", DNSName: "example.com.", Nameservers: providerNS.Nameservers},
+	}
+	svc.RecordMap = map[string]FakeRecords{
+		"test": FakeRecords{
+			recordKey(providerNS): providerNS,
+			recordKey(txt):        txt,
+			recordKey(address):    address,
+		},
+	}
+
+	desiredAddress := address
+	desiredAddress.Addresses = []string{"5.6.7.8"}
+	records := []Record{
+		desiredAddress,
+		NSRecord{
+			BaseRecord:  BaseRecord{Name: "example.com.", TTL: 21600},
+			Nameservers: []string{"ns.backup.net."},
+		},
+	}
+	plan, err := Sync(svc, zone, records, Options{Force: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 3 {
+		t.Errorf("expected the apex A and NS records to be updated and the TXT record deleted, saw: %v", plan)
+	}
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual([]Record{
+		desiredAddress,
+		NSRecord{
+			BaseRecord:  BaseRecord{Name: "example.com.", TTL: 21600},
+			Nameservers: []string{"ns1.provider.net.", "ns2.provider.net.", "ns.backup.net."},
+		},
+	}, recordsOut, t)
+
+	// Leaving the apex NS record out of the config never deletes it.
+	plan, err = Sync(svc, zone, records[:1], Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 0 || len(plan.Skipped) != 1 || plan.Skipped[0].Reason != "zone nameservers" {
+		t.Errorf("expected the apex NS record to be skipped, saw: %v", plan)
+	}
+
+	// A config can't remove the zone's own nameservers.
+	plan, err = Sync(svc, zone, []Record{desiredAddress, providerNS}, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 1 || len(plan.Changes[0].Desired.RRData()) != 2 {
+		t.Errorf("expected only the extra nameserver to be removed, saw: %v", plan)
+	}
+
+	// Nor can it by listing other nameservers for the zone; the ones the zone
+	// is served from are protected.
+	zone.Nameservers = []string{"ns.backup.net."}
+	planned, err := PlanSync(svc, zone, []Record{desiredAddress, NSRecord{
+		BaseRecord:  BaseRecord{Name: "example.com.", TTL: 21600},
+		Nameservers: zone.Nameservers,
+	}}, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(planned.Changes) != 1 || len(planned.Changes[0].Desired.RRData()) != 3 {
+		t.Errorf("expected the zone's nameservers to be kept, saw: %v", planned)
+	}
+}
+
+func TestZonesEqualNameservers(t *testing.T) {
+	provider := Zone{Name: "test", DNSName: "example.com.", Nameservers: []string{"ns1.provider.net.", "ns2.provider.net."}}
+	tests := []struct {
+		nameservers []string
+		equal       bool
+	}{
+		{nameservers: nil, equal: true},
+		{nameservers: []string{"NS2.provider.net", "ns1.provider.net."}, equal: true},
+		{nameservers: []string{"ns1.provider.net."}, equal: false},
+	}
+	for ix, test := range tests {
+		zone := Zone{Name: "test", DNSName: "example.com", Nameservers: test.nameservers}
+		if zonesEqual(zone, provider) != test.equal {
+			t.Errorf("[%d] expected equal to be %v", ix, test.equal)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package dns
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// canonicalName returns the form of a DNS name used for comparisons: lower
+// case A-labels, fully qualified with a trailing dot.
+func canonicalName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if ascii, err := ToASCII(name); err == nil {
+		name = ascii
+	}
+	if len(name) == 0 || strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// isWildcard returns true for names like *.example.com.
+func isWildcard(name string) bool {
+	return strings.HasPrefix(canonicalName(name), "*.")
+}
+
+// wildcardMatches returns true if the wildcard name covers name, e.g.
+// *.example.com. covers www.example.com. and a.b.example.com., but not
+// example.com. itself.
+func wildcardMatches(wildcard, name string) bool {
+	if !isWildcard(wildcard) {
+		return false
+	}
+	suffix := canonicalName(wildcard)[1:]
+	name = canonicalName(name)
+	return len(name) > len(suffix) && strings.HasSuffix(name, suffix)
+}
+
+// canonicalType returns the upper case form of a record type.
+func canonicalType(kind string) string {
+	return strings.ToUpper(strings.TrimSpace(kind))
+}
+
+// recordKey identifies a record set within a zone by its name and type.
+func recordKey(record Record) string {
+	return canonicalName(record.RecordName()) + " " + canonicalType(record.Type())
+}
+
+// canonicalAddress returns the normalized text form of an IP address, or the
+// trimmed input if it doesn't parse.
+func canonicalAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+	return address
+}
+
+// canonicalSet applies fn to every value and returns the sorted result with
+// duplicates removed.
+func canonicalSet(values []string, fn func(string) string) []string {
+	result := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = fn(value)
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// canonicalText strips the quotes that some providers put around TXT values.
+func canonicalText(text string) string {
+	if len(text) >= 2 && strings.HasPrefix(text, "\"") && strings.HasSuffix(text, "\"") {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
@@ -38,43 +38,61 @@ func NewAzureDNSService() (dns.Service, error) {
 func (g *azureDNS) Zones() ([]dns.Zone, error) {
 	list, err := g.zonesClient.List(context.TODO(), nil)
 	if err != nil {
-		return nil, err
+		return nil, transient(err)
 	}
 	result := []dns.Zone{}
 	for ix := range list.Values() {
-		result = append(result, makeZone(&list.Values()[ix]))
+		result = append(result, dns.UnicodeZone(makeZone(&list.Values()[ix])))
 	}
 	return result, nil
 }
 
 func (g *azureDNS) WriteZone(zone dns.Zone, create bool) error {
-	_, err := g.zonesClient.CreateOrUpdate(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), makeAzureZone(zone), "", "")
-	return err
+	zone, err := dns.ASCIIZone(zone)
+	if err != nil {
+		return err
+	}
+	_, err = g.zonesClient.CreateOrUpdate(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), makeAzureZone(zone), "", "")
+	return transient(err)
 }
 
 func (g *azureDNS) DeleteZone(zone dns.Zone) error {
-	_, err := g.zonesClient.Delete(context.TODO(), g.resourceGroup, zone.DNSName, "")
-	return err
+	zone, err := dns.ASCIIZone(zone)
+	if err != nil {
+		return err
+	}
+	_, err = g.zonesClient.Delete(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), "")
+	return transient(err)
 }
 
 func (g *azureDNS) WriteRecord(zone dns.Zone, oldRecord, newRecord dns.Record) error {
+	zone, err := dns.ASCIIZone(zone)
+	if err != nil {
+		return err
+	}
+	if newRecord, err = dns.ASCIIRecord(newRecord); err != nil {
+		return err
+	}
 	ttl := newRecord.TimeToLive()
 	properties := azuredns.RecordSetProperties{
 		TTL: &ttl,
 	}
 	switch newRecord.Type() {
 	case "A":
-		properties.ARecords = &[]azuredns.ARecord{
-			azuredns.ARecord{
-				Ipv4Address: &newRecord.RRData()[0],
-			},
+		rrdata := newRecord.RRData()
+		arr := make([]azuredns.ARecord, len(rrdata))
+		for ix := range rrdata {
+			arr[ix] = azuredns.ARecord{
+				Ipv4Address: &rrdata[ix],
+			}
 		}
+		properties.ARecords = &arr
 	case "NS":
 		rrdata := newRecord.RRData()
 		arr := make([]azuredns.NsRecord, len(rrdata))
 		for ix := range rrdata {
 			arr[ix] = azuredns.NsRecord{
-				Nsdname: &newRecord.RRData()[0],
+				Nsdname: &rrdata[ix],
 			}
 		}
 		properties.NsRecords = &arr
@@ -82,50 +100,92 @@ func (g *azureDNS) WriteRecord(zone dns.Zone, oldRecord, newRecord dns.Record) e
 		properties.CnameRecord = &azuredns.CnameRecord{
 			Cname: &newRecord.RRData()[0],
 		}
+	case "TXT":
+		rrdata := newRecord.RRData()
+		arr := make([]azuredns.TxtRecord, len(rrdata))
+		for ix := range rrdata {
+			arr[ix] = azuredns.TxtRecord{
+				Value: &[]string{rrdata[ix]},
+			}
+		}
+		properties.TxtRecords = &arr
+	case "SOA":
+		soa := newRecord.(dns.SOARecord)
+		refresh, retry, expire, minimum := int64(soa.Refresh), int64(soa.Retry), int64(soa.Expire), int64(soa.Minimum)
+		properties.SoaRecord = &azuredns.SoaRecord{
+			Host:         strPtr(soa.Nameserver),
+			Email:        strPtr(removeTrailingDot(soa.Email)),
+			SerialNumber: &soa.Serial,
+			RefreshTime:  &refresh,
+			RetryTime:    &retry,
+			ExpireTime:   &expire,
+			MinimumTTL:   &minimum,
+		}
 	}
-	name := removeTrailingDot(removeSuffix(newRecord.RecordName(), zone.DNSName))
+	name := relativeName(zone, newRecord)
 	recordType := newRecord.Type()
 	recordSet := azuredns.RecordSet{
 		Name:                &name,
 		Type:                &recordType,
 		RecordSetProperties: &properties,
 	}
-	_, err := g.recordsClient.CreateOrUpdate(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), name, azuredns.RecordType(newRecord.Type()), recordSet, "", "")
-	return err
+	_, err = g.recordsClient.CreateOrUpdate(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), name, azuredns.RecordType(newRecord.Type()), recordSet, "", "")
+	return transient(err)
 }
 
 func (g *azureDNS) Records(zone dns.Zone) ([]dns.Record, error) {
-	list, err := g.recordsClient.ListAllByDNSZone(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), nil, "")
+	zone, err := dns.ASCIIZone(zone)
 	if err != nil {
 		return nil, err
 	}
+	list, err := g.recordsClient.ListAllByDNSZone(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), nil, "")
+	if err != nil {
+		return nil, transient(err)
+	}
 	items := list.Values()
 	result := []dns.Record{}
 	for ix := range items {
 		record := makeRecordFromAzureRecord(zone, items[ix])
 		if record != nil {
-			result = append(result, record)
+			result = append(result, dns.UnicodeRecord(record))
 		}
 	}
 	return result, nil
 }
 
 func (g *azureDNS) DeleteRecord(zone dns.Zone, record dns.Record) error {
-	_, err := g.recordsClient.Delete(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), record.RecordName(), azuredns.RecordType(record.Type()), "")
-	return err
+	zone, err := dns.ASCIIZone(zone)
+	if err != nil {
+		return err
+	}
+	if record, err = dns.ASCIIRecord(record); err != nil {
+		return err
+	}
+	_, err = g.recordsClient.Delete(context.TODO(), g.resourceGroup, removeTrailingDot(zone.DNSName), relativeName(zone, record), azuredns.RecordType(record.Type()), "")
+	return transient(err)
 }
 
 func makeRecordFromAzureRecord(zone dns.Zone, record azuredns.RecordSet) dns.Record {
 	name := *record.Name + "." + zone.DNSName
-	switch *record.Type {
+	if *record.Name == "@" {
+		name = zone.DNSName
+	}
+	// Types are returned as e.g. Microsoft.Network/dnszones/A.
+	recordType := *record.Type
+	recordType = recordType[strings.LastIndex(recordType, "/")+1:]
+	switch recordType {
 	case "A":
+		addresses := []string{}
+		for _, record := range *record.RecordSetProperties.ARecords {
+			addresses = append(addresses, *record.Ipv4Address)
+		}
 		return dns.AddressRecord{
 			BaseRecord: dns.BaseRecord{
 				Name: name,
 				Kind: "A",
-				TTL:  *record.TTL,
+				TTL:  dns.TTL(*record.TTL),
 			},
-			Addresses: []string{*(*record.RecordSetProperties.ARecords)[0].Ipv4Address},
+			Addresses: addresses,
 		}
 	case "NS":
 		nameservers := []string{}
@@ -136,7 +196,7 @@ func makeRecordFromAzureRecord(zone dns.Zone, record azuredns.RecordSet) dns.Rec
 			BaseRecord: dns.BaseRecord{
 				Name: name,
 				Kind: "NS",
-				TTL:  *record.TTL,
+				TTL:  dns.TTL(*record.TTL),
 			},
 			Nameservers: nameservers,
 		}
@@ -145,10 +205,39 @@ func makeRecordFromAzureRecord(zone dns.Zone, record azuredns.RecordSet) dns.Rec
 			BaseRecord: dns.BaseRecord{
 				Name: name,
 				Kind: "CNAME",
-				TTL:  *record.TTL,
+				TTL:  dns.TTL(*record.TTL),
 			},
 			CanonicalName: *(*record.RecordSetProperties.CnameRecord).Cname,
 		}
+	case "TXT":
+		text := []string{}
+		for _, record := range *record.RecordSetProperties.TxtRecords {
+			text = append(text, strings.Join(*record.Value, ""))
+		}
+		return dns.TXTRecord{
+			BaseRecord: dns.BaseRecord{
+				Name: name,
+				Kind: "TXT",
+				TTL:  dns.TTL(*record.TTL),
+			},
+			Text: text,
+		}
+	case "SOA":
+		soa := record.RecordSetProperties.SoaRecord
+		return dns.SOARecord{
+			BaseRecord: dns.BaseRecord{
+				Name: name,
+				Kind: "SOA",
+				TTL:  dns.TTL(*record.TTL),
+			},
+			Nameserver: *soa.Host,
+			Email:      addTrailingDot(*soa.Email),
+			Serial:     *soa.SerialNumber,
+			Refresh:    dns.TTL(*soa.RefreshTime),
+			Retry:      dns.TTL(*soa.RetryTime),
+			Expire:     dns.TTL(*soa.ExpireTime),
+			Minimum:    dns.TTL(*soa.MinimumTTL),
+		}
 	}
 	return nil
 }
@@ -166,6 +255,8 @@ func makeZone(zone *azuredns.Zone) dns.Zone {
 		Description: tagOrEmptyString(zone.Tags, "description"),
 		DNSName:     addTrailingDot(*zone.Name),
 		Nameservers: *zone.ZoneProperties.NameServers,
+		Managed:     tagOrEmptyString(zone.Tags, managedByKey) == managedByValue,
+		Owner:       tagOrEmptyString(zone.Tags, ownerKey),
 	}
 }
 
@@ -183,19 +274,35 @@ func addTrailingDot(val string) string {
 }
 
 func makeAzureZone(zone dns.Zone) azuredns.Zone {
+	tags := map[string]*string{
+		"name":        &zone.Name,
+		"description": &zone.Description,
+	}
+	if zone.Managed {
+		tags[managedByKey] = strPtr(managedByValue)
+		tags[ownerKey] = &zone.Owner
+	}
 	return azuredns.Zone{
 		Name:     strPtr(removeTrailingDot(zone.DNSName)),
 		Location: strPtr("global"),
-		Tags: map[string]*string{
-			"name":        &zone.Name,
-			"description": &zone.Description,
-		},
+		Tags:     tags,
 		ZoneProperties: &azuredns.ZoneProperties{
 			NameServers: &zone.Nameservers,
 		},
 	}
 }
 
+// relativeName returns the name of a record relative to its zone, which is
+// how Azure names record sets, e.g. "www", "*" for a wildcard or "@" for the
+// apex.
+func relativeName(zone dns.Zone, record dns.Record) string {
+	name := removeTrailingDot(removeSuffix(record.RecordName(), zone.DNSName))
+	if len(name) == 0 {
+		return "@"
+	}
+	return name
+}
+
 func removeSuffix(str string, suffix string) string {
 	if strings.HasSuffix(str, suffix) {
 		return str[0 : len(str)-len(suffix)]
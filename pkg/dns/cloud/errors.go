@@ -0,0 +1,23 @@
+package cloud
+
+import (
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/brendandburns/dns-sync/pkg/dns"
+	"google.golang.org/api/googleapi"
+)
+
+// transient returns a *dns.TransientError wrapping err if it is a throttling
+// or server error from either provider, so that it can be retried.
+func transient(err error) error {
+	switch e := err.(type) {
+	case *googleapi.Error:
+		return dns.TransientStatus(err, e.Code, e.Header)
+	case autorest.DetailedError:
+		code, ok := e.StatusCode.(int)
+		if !ok || e.Response == nil {
+			return err
+		}
+		return dns.TransientStatus(err, code, e.Response.Header)
+	}
+	return err
+}
@@ -3,6 +3,8 @@ package cloud
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/brendandburns/dns-sync/pkg/dns"
 	cloud_dns "google.golang.org/api/dns/v1"
@@ -37,33 +39,45 @@ func NewGoogleCloudDNSService() (dns.Service, error) {
 func (g *googleDNS) Zones() ([]dns.Zone, error) {
 	list, err := g.client.ManagedZones.List(g.project).Do()
 	if err != nil {
-		return nil, err
+		return nil, transient(err)
 	}
 	result := make([]dns.Zone, len(list.ManagedZones))
 	for ix, zone := range list.ManagedZones {
-		result[ix] = dns.Zone{
+		result[ix] = dns.UnicodeZone(dns.Zone{
 			Name:        zone.Name,
 			DNSName:     zone.DnsName,
 			Nameservers: zone.NameServers,
-		}
+			Managed:     zone.Labels[managedByKey] == managedByValue,
+			Owner:       zone.Labels[ownerKey],
+		})
 	}
 	return result, nil
 }
 
 func (g *googleDNS) WriteZone(zone dns.Zone, create bool) error {
+	zone, err := dns.ASCIIZone(zone)
+	if err != nil {
+		return err
+	}
 	cloudZone := cloud_dns.ManagedZone{
 		Name:        zone.Name,
 		DnsName:     zone.DNSName,
 		NameServers: zone.Nameservers,
 		Description: zone.Description,
 	}
+	if zone.Managed {
+		cloudZone.Labels = map[string]string{
+			managedByKey: managedByValue,
+			ownerKey:     dns.ZoneLabel(zone.Owner),
+		}
+	}
 	if create {
-		_, err := g.client.ManagedZones.Create(g.project, &cloudZone).Do()
-		return err
+		_, err = g.client.ManagedZones.Create(g.project, &cloudZone).Do()
+		return transient(err)
 	}
 	currentZone, err := g.client.ManagedZones.Get(g.project, zone.Name).Do()
 	if err != nil {
-		return err
+		return transient(err)
 	}
 	currentZone.Name = zone.Name
 	currentZone.DnsName = zone.DNSName
@@ -72,61 +86,84 @@ func (g *googleDNS) WriteZone(zone dns.Zone, create bool) error {
 		currentZone.NameServers = zone.Nameservers
 	}
 	_, err = g.client.ManagedZones.Update(g.project, zone.Name, currentZone).Do()
-	return err
+	return transient(err)
 }
 
 func (g *googleDNS) DeleteZone(zone dns.Zone) error {
-	return g.client.ManagedZones.Delete(g.project, zone.Name).Do()
+	return transient(g.client.ManagedZones.Delete(g.project, zone.Name).Do())
 }
 
 func (g *googleDNS) WriteRecord(zone dns.Zone, oldRecord, newRecord dns.Record) error {
-	recordSet := makeRecordSet(newRecord)
+	recordSet, err := makeRecordSet(newRecord)
+	if err != nil {
+		return err
+	}
 	change := cloud_dns.Change{
 		Additions: []*cloud_dns.ResourceRecordSet{recordSet},
 	}
 	if oldRecord != nil {
-		deleteSet := makeRecordSet(oldRecord)
+		deleteSet, err := makeRecordSet(oldRecord)
+		if err != nil {
+			return err
+		}
 		change.Deletions = []*cloud_dns.ResourceRecordSet{deleteSet}
 	}
-	_, err := g.client.Changes.Create(g.project, zone.Name, &change).Do()
-	return err
+	_, err = g.client.Changes.Create(g.project, zone.Name, &change).Do()
+	return transient(err)
 }
 
 func (g *googleDNS) Records(zone dns.Zone) ([]dns.Record, error) {
 	list, err := g.client.ResourceRecordSets.List(g.project, zone.Name).Do()
 	if err != nil {
-		return nil, err
+		return nil, transient(err)
 	}
 	result := []dns.Record{}
 	for _, record := range list.Rrsets {
 		if cloudRecord, err := makeRecord(record); err == nil {
-			result = append(result, cloudRecord)
+			result = append(result, dns.UnicodeRecord(cloudRecord))
 		}
 	}
 	return result, nil
 }
 
 func (g *googleDNS) DeleteRecord(zone dns.Zone, record dns.Record) error {
+	recordSet, err := makeRecordSet(record)
+	if err != nil {
+		return err
+	}
 	change := cloud_dns.Change{
-		Deletions: []*cloud_dns.ResourceRecordSet{makeRecordSet(record)},
+		Deletions: []*cloud_dns.ResourceRecordSet{recordSet},
 	}
-	_, err := g.client.Changes.Create(g.project, zone.Name, &change).Do()
-	return err
+	_, err = g.client.Changes.Create(g.project, zone.Name, &change).Do()
+	return transient(err)
 }
 
-func makeRecordSet(record dns.Record) *cloud_dns.ResourceRecordSet {
+// makeRecordSet converts a record to a record set, with names in A-label
+// form.
+func makeRecordSet(record dns.Record) (*cloud_dns.ResourceRecordSet, error) {
+	record, err := dns.ASCIIRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	rrdatas := record.RRData()
+	if record.Type() == "TXT" {
+		rrdatas = make([]string, len(record.RRData()))
+		for ix, text := range record.RRData() {
+			rrdatas[ix] = quoteTXT(text)
+		}
+	}
 	return &cloud_dns.ResourceRecordSet{
 		Type:    record.Type(),
 		Name:    record.RecordName(),
 		Ttl:     record.TimeToLive(),
-		Rrdatas: record.RRData(),
-	}
+		Rrdatas: rrdatas,
+	}, nil
 }
 
 func makeRecord(recordSet *cloud_dns.ResourceRecordSet) (dns.Record, error) {
 	baseRecord := dns.BaseRecord{
 		Name: recordSet.Name,
-		TTL:  recordSet.Ttl,
+		TTL:  dns.TTL(recordSet.Ttl),
 		Kind: recordSet.Type,
 	}
 	if recordSet.Type == "A" {
@@ -147,5 +184,33 @@ func makeRecord(recordSet *cloud_dns.ResourceRecordSet) (dns.Record, error) {
 			Nameservers: recordSet.Rrdatas,
 		}, nil
 	}
+	if recordSet.Type == "SOA" && len(recordSet.Rrdatas) == 1 {
+		return dns.ParseSOA(baseRecord, recordSet.Rrdatas[0])
+	}
+	if recordSet.Type == "TXT" {
+		text := make([]string, len(recordSet.Rrdatas))
+		for ix, rrdata := range recordSet.Rrdatas {
+			text[ix] = unquoteTXT(rrdata)
+		}
+		return dns.TXTRecord{
+			BaseRecord: baseRecord,
+			Text:       text,
+		}, nil
+	}
 	return nil, fmt.Errorf("Unsupported record type: %s", recordSet.Type)
 }
+
+// Cloud DNS stores TXT data as quoted character strings.
+func quoteTXT(text string) string {
+	if strings.HasPrefix(text, "\"") && strings.HasSuffix(text, "\"") && len(text) >= 2 {
+		return text
+	}
+	return strconv.Quote(text)
+}
+
+func unquoteTXT(text string) string {
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		return unquoted
+	}
+	return text
+}
@@ -0,0 +1,9 @@
+package cloud
+
+// Zones that dns-sync created are marked with these tags or labels, so that
+// pruning never deletes zones created by anything else.
+const (
+	managedByKey   = "managed-by"
+	managedByValue = "dns-sync"
+	ownerKey       = "dns-sync-owner"
+)
@@ -0,0 +1,243 @@
+package dns
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	defaultInterval     = 5 * time.Minute
+	defaultPollInterval = 5 * time.Second
+	defaultRetryDelay   = 10 * time.Second
+	defaultJitter       = 0.1
+)
+
+// Daemon keeps a zone in sync with a config file. It syncs whenever the
+// config, or a file it includes, changes and otherwise every Interval, so
+// that changes made outside of dns-sync are reverted. Failed syncs are
+// retried after RetryDelay, doubling on every consecutive failure up to
+// Interval.
+type Daemon struct {
+	ConfigFile string
+	// Overrides sets variables declared in the config.
+	Overrides map[string]string
+	Service   Service
+	// Options are used for every sync, with the ignore rules of the config
+	// added.
+	Options Options
+
+	Interval     time.Duration
+	PollInterval time.Duration
+	RetryDelay   time.Duration
+	// Jitter randomly spreads delays by up to this fraction, e.g. 0.1 for
+	// plus or minus 10%.
+	Jitter float64
+	// StatusAddress, if set, is the address the status is served on, at
+	// /status.
+	StatusAddress string
+
+	lock   sync.Mutex
+	status DaemonStatus
+	// files maps every config file, and every included directory, to its
+	// modification time at the last sync, so that adding a file to an
+	// included directory is a change too.
+	files  map[string]time.Time
+	random func() float64
+}
+
+// DaemonStatus describes the most recent sync.
+type DaemonStatus struct {
+	Runs                int       `json:"runs"`
+	LastRun             time.Time `json:"lastRun"`
+	LastSuccess         time.Time `json:"lastSuccess"`
+	Duration            string    `json:"duration"`
+	Error               string    `json:"error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	Changes             []string  `json:"changes"`
+	Skipped             []string  `json:"skipped"`
+	NextRun             time.Time `json:"nextRun"`
+}
+
+// Healthy returns true if the last sync succeeded.
+func (s DaemonStatus) Healthy() bool {
+	return s.Runs > 0 && s.ConsecutiveFailures == 0
+}
+
+// Status returns the status of the most recent sync.
+func (d *Daemon) Status() DaemonStatus {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.status
+}
+
+// ServeHTTP reports the status as JSON, with a 503 status code if the last
+// sync failed or there hasn't been one yet.
+func (d *Daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := d.Status()
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(data)
+}
+
+// Run syncs until stop is closed. It returns an error if the status can't
+// be served.
+func (d *Daemon) Run(stop <-chan struct{}) error {
+	failed := make(chan error, 1)
+	if len(d.StatusAddress) > 0 {
+		listener, err := net.Listen("tcp", d.StatusAddress)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/status", d)
+		server := &http.Server{Handler: mux}
+		go func() {
+			failed <- server.Serve(listener)
+		}()
+		defer server.Close()
+	}
+	next := time.Now()
+	for {
+		if !time.Now().Before(next) || d.configChanged() {
+			err := d.runOnce()
+			next = time.Now().Add(d.delay(err))
+			d.lock.Lock()
+			d.status.NextRun = next
+			d.lock.Unlock()
+		}
+		select {
+		case <-stop:
+			return nil
+		case err := <-failed:
+			return err
+		case <-time.After(d.pollInterval()):
+		}
+	}
+}
+
+// runOnce loads the config and syncs it, recording the result.
+func (d *Daemon) runOnce() error {
+	start := time.Now()
+	files := map[string]time.Time{d.ConfigFile: modTime(d.ConfigFile)}
+	result, err := d.sync(files)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.files = files
+	d.status.Runs++
+	d.status.LastRun = start
+	d.status.Duration = time.Since(start).String()
+	d.status.Changes = []string{}
+	d.status.Skipped = []string{}
+	if result != nil {
+		for _, change := range result.Changes {
+			d.status.Changes = append(d.status.Changes, change.String())
+		}
+		for _, skip := range result.Skipped {
+			d.status.Skipped = append(d.status.Skipped, skip.String())
+		}
+	}
+	if err != nil {
+		glog.Errorf("Sync failed: %v", err)
+		d.status.Error = err.Error()
+		d.status.ConsecutiveFailures++
+		return err
+	}
+	glog.Infof("Synchronized %s, %d changes.", d.ConfigFile, len(d.status.Changes))
+	d.status.Error = ""
+	d.status.ConsecutiveFailures = 0
+	d.status.LastSuccess = start
+	return nil
+}
+
+func (d *Daemon) sync(files map[string]time.Time) (*Result, error) {
+	config, err := LoadConfig(d.ConfigFile, d.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range config.files {
+		files[file] = modTime(file)
+	}
+	for _, dir := range config.dirs {
+		files[dir] = modTime(dir)
+	}
+	options := d.Options
+	options.Ignore = append(append([]IgnoreRule{}, options.Ignore...), config.Ignore...)
+	return Sync(d.Service, config.Zone, config.Records, options)
+}
+
+// configChanged returns true if any config file or included directory changed
+// since the last sync.
+func (d *Daemon) configChanged() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for file, synced := range d.files {
+		if !modTime(file).Equal(synced) {
+			glog.V(2).Infof("%s changed.", file)
+			return true
+		}
+	}
+	return false
+}
+
+// modTime returns the modification time of a file, or the zero time if it
+// can't be read, so that a file appearing or disappearing is a change.
+func modTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// delay returns how long to wait before the next sync, after a sync that
+// returned err.
+func (d *Daemon) delay(err error) time.Duration {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	delay := interval
+	if err != nil {
+		delay = d.RetryDelay
+		if delay <= 0 {
+			delay = defaultRetryDelay
+		}
+		for failures := d.Status().ConsecutiveFailures; failures > 1 && delay < interval; failures-- {
+			delay *= 2
+		}
+		if delay > interval {
+			delay = interval
+		}
+	}
+	jitter := d.Jitter
+	if jitter == 0 {
+		jitter = defaultJitter
+	}
+	random := d.random
+	if random == nil {
+		random = rand.Float64
+	}
+	return delay + time.Duration(float64(delay)*jitter*(2*random()-1))
+}
+
+func (d *Daemon) pollInterval() time.Duration {
+	if d.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return d.PollInterval
+}
@@ -0,0 +1,214 @@
+package dns
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonDelay(t *testing.T) {
+	d := &Daemon{
+		Interval:   time.Minute,
+		RetryDelay: 10 * time.Second,
+		random:     func() float64 { return 0.5 },
+	}
+	if delay := d.delay(nil); delay != time.Minute {
+		t.Errorf("expected the interval, saw %v", delay)
+	}
+	failed := errors.New("failed")
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for ix, delay := range expected {
+		d.status.ConsecutiveFailures = ix + 1
+		if actual := d.delay(failed); actual != delay {
+			t.Errorf("[%d] expected %v, saw %v", ix, delay, actual)
+		}
+	}
+
+	d.status.ConsecutiveFailures = 0
+	d.random = func() float64 { return 1 }
+	if delay := d.delay(nil); delay != 66*time.Second {
+		t.Errorf("expected 10%% jitter, saw %v", delay)
+	}
+	d.random = func() float64 { return 0 }
+	if delay := d.delay(nil); delay != 54*time.Second {
+		t.Errorf("expected 10%% jitter, saw %v", delay)
+	}
+}
+
+const daemonConfig = `zone:
+  name: test
+  dnsName: example.com.
+records:
+- kind: A
+  ttl: 300
+  name: www.example.com.
+  addresses:
+  - 1.2.3.4
+`
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDaemonRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": daemonConfig})
+	file := filepath.Join(dir, "config.yaml")
+	svc := &FakeDNSService{}
+	d := &Daemon{
+		ConfigFile:   file,
+		Service:      svc,
+		Interval:     time.Hour,
+		PollInterval: time.Millisecond,
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		if err := d.Run(stop); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		close(done)
+	}()
+	waitFor(t, func() bool { return d.Status().Runs == 1 })
+	if status := d.Status(); !status.Healthy() || len(status.Changes) != 1 {
+		t.Errorf("unexpected status: %v", status)
+	}
+
+	// Changing the config syncs again without waiting for the interval.
+	if err := ioutil.WriteFile(file, []byte(strings.Replace(daemonConfig, "1.2.3.4", "5.6.7.8", 1)), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return d.Status().Runs == 2 })
+	records, err := svc.Records(Zone{Name: "test"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].RRData()[0] != "5.6.7.8" {
+		t.Errorf("unexpected records: %v", records)
+	}
+
+	// A broken config is reported and retried once it is fixed.
+	if err := ioutil.WriteFile(file, []byte("zone: ["), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return d.Status().Runs == 3 })
+	status := d.Status()
+	if status.Healthy() || len(status.Error) == 0 || status.ConsecutiveFailures != 1 {
+		t.Errorf("unexpected status: %v", status)
+	}
+	recorder := httptest.NewRecorder()
+	d.ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503, saw %d", recorder.Code)
+	}
+
+	if err := ioutil.WriteFile(file, []byte(daemonConfig), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return d.Status().Runs == 4 })
+	close(stop)
+	<-done
+
+	recorder = httptest.NewRecorder()
+	d.ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected a 200, saw %d", recorder.Code)
+	}
+	reported := DaemonStatus{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &reported); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if reported.Runs != 4 || reported.ConsecutiveFailures != 0 || len(reported.Changes) != 1 {
+		t.Errorf("unexpected status: %v", reported)
+	}
+}
+
+func TestDaemonRunIncludedDirectory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":  daemonConfig + "include:\n- teams\n",
+		"teams/a.yaml": "records: []\n",
+	})
+	svc := &FakeDNSService{}
+	d := &Daemon{
+		ConfigFile:   filepath.Join(dir, "config.yaml"),
+		Service:      svc,
+		Interval:     time.Hour,
+		PollInterval: time.Millisecond,
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		if err := d.Run(stop); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	waitFor(t, func() bool { return d.Status().Runs == 1 })
+
+	// A file added to an included directory syncs again.
+	teams := filepath.Join(dir, "teams")
+	data := "records:\n- kind: A\n  ttl: 60\n  name: b.example.com.\n  addresses:\n  - 2.3.4.5\n"
+	if err := ioutil.WriteFile(filepath.Join(teams, "b.yaml"), []byte(data), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(teams, later, later); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return d.Status().Runs == 2 })
+	records, err := svc.Records(Zone{Name: "test"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("unexpected records: %v", records)
+	}
+}
+
+func TestDaemonRunStatusAddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer listener.Close()
+	d := &Daemon{
+		ConfigFile:    filepath.Join(t.TempDir(), "config.yaml"),
+		Service:       &FakeDNSService{},
+		StatusAddress: listener.Addr().String(),
+	}
+	if err := d.Run(make(chan struct{})); err == nil {
+		t.Errorf("expected an error")
+	}
+	if d.Status().Runs != 0 {
+		t.Errorf("expected no syncs")
+	}
+}
@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TTL is a time to live in seconds. In configs it can be written as a number
+// of seconds, or as a string with units, e.g. "90s", "5m", "1h30m", "1d" or
+// "1w".
+type TTL int64
+
+var ttlUnits = map[byte]int64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+	'w': 7 * 24 * 60 * 60,
+}
+
+// ParseTTL parses a TTL written in seconds or with units.
+func ParseTTL(value string) (TTL, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return TTL(seconds), nil
+	}
+	if len(value) == 0 {
+		return 0, fmt.Errorf("empty ttl")
+	}
+	var total int64
+	for len(value) > 0 {
+		digits := 0
+		for digits < len(value) && value[digits] >= '0' && value[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits == len(value) {
+			return 0, fmt.Errorf("invalid ttl: %s", value)
+		}
+		unit, found := ttlUnits[value[digits]]
+		if !found {
+			return 0, fmt.Errorf("invalid ttl unit '%c', expected one of s, m, h, d or w", value[digits])
+		}
+		count, err := strconv.ParseInt(value[:digits], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl: %v", err)
+		}
+		if count > (math.MaxInt64-total)/unit {
+			return 0, fmt.Errorf("ttl is too large")
+		}
+		total += count * unit
+		value = value[digits+1:]
+	}
+	return TTL(total), nil
+}
+
+// ttlError is returned when a ttl field can't be parsed, so the loader can
+// point at the field.
+type ttlError struct {
+	err error
+}
+
+func (t *ttlError) Error() string {
+	return t.err.Error()
+}
+
+func (t *TTL) UnmarshalJSON(b []byte) error {
+	var seconds int64
+	if err := json.Unmarshal(b, &seconds); err == nil {
+		*t = TTL(seconds)
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(b, &value); err != nil {
+		return &ttlError{fmt.Errorf("expected a number of seconds or a duration like '5m'")}
+	}
+	ttl, err := ParseTTL(value)
+	if err != nil {
+		return &ttlError{err}
+	}
+	*t = ttl
+	return nil
+}
+
+// RecordDefaults are applied to records that don't set their TTL, which is
+// the only field with a default. The default for the record's kind takes
+// precedence. Kinds is keyed by upper case record type once loaded.
+type RecordDefaults struct {
+	TTL   TTL                     `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Kinds map[string]KindDefaults `json:"kinds,omitempty" yaml:"kinds,omitempty"`
+}
+
+// KindDefaults are the defaults for records of a single kind.
+type KindDefaults struct {
+	TTL TTL `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+}
+
+func (d RecordDefaults) ttlFor(kind string) TTL {
+	if defaults, found := d.Kinds[canonicalType(kind)]; found && defaults.TTL > 0 {
+		return defaults.TTL
+	}
+	return d.TTL
+}
+
+// withTTL returns a copy of record with the TTL set.
+func withTTL(record Record, ttl TTL) Record {
+	switch r := record.(type) {
+	case AddressRecord:
+		r.TTL = ttl
+		return r
+	case CNameRecord:
+		r.TTL = ttl
+		return r
+	case NSRecord:
+		r.TTL = ttl
+		return r
+	case TXTRecord:
+		r.TTL = ttl
+		return r
+	}
+	return record
+}
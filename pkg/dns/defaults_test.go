@@ -0,0 +1,135 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected TTL
+		err      bool
+	}{
+		{value: "300", expected: 300},
+		{value: "90s", expected: 90},
+		{value: "5m", expected: 300},
+		{value: "1h30m", expected: 5400},
+		{value: "1d", expected: 86400},
+		{value: "2w", expected: 1209600},
+		{value: "", err: true},
+		{value: "5", expected: 5},
+		{value: "m", err: true},
+		{value: "5y", err: true},
+		{value: "1h30", err: true},
+		{value: "9223372036854775807s", expected: 9223372036854775807},
+		{value: "15250284452472w", err: true},
+		{value: "9223372036854775807s1s", err: true},
+	}
+	for _, test := range tests {
+		ttl, err := ParseTTL(test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("expected an error for '%s'", test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for '%s': %v", test.value, err)
+		}
+		if ttl != test.expected {
+			t.Errorf("expected %d for '%s', saw %d", test.expected, test.value, ttl)
+		}
+	}
+}
+
+const defaultsConfig = `zone:
+  name: test
+  dnsName: example.com.
+defaults:
+  ttl: 1h
+  kinds:
+    cname:
+      ttl: 5m
+records:
+- kind: A
+  name: www.example.com.
+  addresses:
+  - 1.2.3.4
+- kind: CNAME
+  name: cname.example.com.
+  canonicalName: www.example.com.
+- kind: A
+  ttl: 90s
+  name: ftp.example.com.
+  addresses:
+  - 1.2.3.4
+- kind: TXT
+  ttl: 0
+  name: txt.example.com.
+  text:
+  - hello
+`
+
+func TestParseConfigDefaults(t *testing.T) {
+	config, err := ParseConfig([]byte(defaultsConfig), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []int64{3600, 300, 90, 0}
+	for ix, record := range config.Records {
+		if record.TimeToLive() != expected[ix] {
+			t.Errorf("expected ttl %d for %v", expected[ix], record)
+		}
+	}
+}
+
+func TestUnmarshalConfigDefaults(t *testing.T) {
+	config := Config{}
+	if err := yaml.Unmarshal([]byte(defaultsConfig), &config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := config.Records[1].TimeToLive(); ttl != 300 {
+		t.Errorf("expected the CNAME default, saw %d", ttl)
+	}
+}
+
+func TestParseConfigDefaultsErrors(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected string
+	}{
+		{
+			config:   strings.Replace(defaultsConfig, "  ttl: 1h\n", "", 1),
+			expected: "9: records[0]: ttl is required, set it or a default ttl",
+		},
+		{
+			config:   strings.Replace(defaultsConfig, "    cname:", "    MX:", 1),
+			expected: "7: defaults.kinds.MX: unknown record type: MX",
+		},
+		{
+			config:   strings.Replace(defaultsConfig, "    cname:", "    CNAME:\n      ttl: 1m\n    cname:", 1),
+			expected: "9: defaults.kinds.cname: defaults for CNAME are already set",
+		},
+		{
+			config:   strings.Replace(defaultsConfig, "ttl: 5m", "ttl: soon", 1),
+			expected: "8: defaults.kinds.cname.ttl: invalid ttl: soon",
+		},
+		{
+			config:   strings.Replace(defaultsConfig, "ttl: 1h", "ttl: 1y", 1),
+			expected: "5: defaults.ttl: invalid ttl unit 'y', expected one of s, m, h, d or w",
+		},
+	}
+	for ix, test := range tests {
+		_, err := ParseConfig([]byte(test.config), nil)
+		if err == nil {
+			t.Errorf("[%d] expected an error", ix)
+			continue
+		}
+		if err.Error() != test.expected {
+			t.Errorf("[%d] expected '%s', saw '%s'", ix, test.expected, err.Error())
+		}
+	}
+}
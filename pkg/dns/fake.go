@@ -2,18 +2,27 @@ package dns
 
 import (
 	"fmt"
+	"sync"
 )
 
+// FakeRecords holds the records of a zone, keyed by name and type.
 type FakeRecords map[string]Record
 
+// FakeDNSService is an in-memory Service for tests. Like real providers, it
+// rejects a CNAME next to other records at the same name. It is safe for
+// concurrent use.
 type FakeDNSService struct {
 	ZoneMap   map[string]Zone
 	RecordMap map[string]FakeRecords
+
+	lock sync.Mutex
 }
 
 var _ = Service(&FakeDNSService{})
 
 func (f *FakeDNSService) Zones() ([]Zone, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	result := []Zone{}
 	for _, value := range f.ZoneMap {
 		result = append(result, value)
@@ -22,6 +31,8 @@ func (f *FakeDNSService) Zones() ([]Zone, error) {
 }
 
 func (f *FakeDNSService) WriteZone(zone Zone, create bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	if f.ZoneMap == nil {
 		f.ZoneMap = map[string]Zone{}
 		f.RecordMap = map[string]FakeRecords{}
@@ -39,14 +50,19 @@ func (f *FakeDNSService) WriteZone(zone Zone, create bool) error {
 }
 
 func (f *FakeDNSService) DeleteZone(zone Zone) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	if f.ZoneMap == nil {
 		return nil
 	}
 	delete(f.ZoneMap, zone.Name)
+	delete(f.RecordMap, zone.Name)
 	return nil
 }
 
 func (f *FakeDNSService) Records(zone Zone) ([]Record, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	result := []Record{}
 	for _, value := range f.RecordMap[zone.Name] {
 		result = append(result, value)
@@ -55,24 +71,36 @@ func (f *FakeDNSService) Records(zone Zone) ([]Record, error) {
 }
 
 func (f *FakeDNSService) WriteRecord(zone Zone, oldRecord, record Record) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	if _, exists := f.RecordMap[zone.Name]; !exists {
 		f.RecordMap[zone.Name] = map[string]Record{}
 	}
-	_, exists := f.RecordMap[zone.Name][record.RecordName()]
+	_, exists := f.RecordMap[zone.Name][recordKey(record)]
 	if oldRecord != nil && !exists {
 		return fmt.Errorf("record doesn't exist!")
 	}
 	if oldRecord == nil && exists {
 		return fmt.Errorf("conflict, record exists")
 	}
-	f.RecordMap[zone.Name][record.RecordName()] = record
+	for key, existing := range f.RecordMap[zone.Name] {
+		if key == recordKey(record) || canonicalName(existing.RecordName()) != canonicalName(record.RecordName()) {
+			continue
+		}
+		if existing.Type() == "CNAME" || record.Type() == "CNAME" {
+			return fmt.Errorf("conflict, CNAME can't coexist with other records")
+		}
+	}
+	f.RecordMap[zone.Name][recordKey(record)] = record
 	return nil
 }
 
 func (f *FakeDNSService) DeleteRecord(zone Zone, record Record) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	if _, exists := f.RecordMap[zone.Name]; !exists {
 		return fmt.Errorf("zone doesn't exist!")
 	}
-	delete(f.RecordMap[zone.Name], record.RecordName())
+	delete(f.RecordMap[zone.Name], recordKey(record))
 	return nil
 }
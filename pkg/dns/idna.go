@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// idnaProfile converts labels with IDNA 2008 lookup rules. Underscores are
+// allowed so that names like _dns-sync.bücher.example. still convert.
+var idnaProfile = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.StrictDomainName(false))
+
+// ToASCII converts a name with Unicode labels to its A-label (punycode) form,
+// e.g. bücher.example. becomes xn--bcher-kva.example. ASCII labels are only
+// lower cased, so wildcards and underscores pass through unchanged.
+func ToASCII(name string) (string, error) {
+	labels := strings.Split(name, ".")
+	for ix, label := range labels {
+		if isASCII(label) {
+			labels[ix] = strings.ToLower(label)
+			continue
+		}
+		ascii, err := idnaProfile.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("has an invalid internationalized label '%s': %v", label, err)
+		}
+		labels[ix] = ascii
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts the A-labels in a name back to Unicode. Labels that
+// don't decode, or don't convert back to the same A-label, are left as they
+// are.
+func ToUnicode(name string) string {
+	labels := strings.Split(name, ".")
+	for ix, label := range labels {
+		if !strings.HasPrefix(strings.ToLower(label), "xn--") {
+			continue
+		}
+		unicode, err := idnaProfile.ToUnicode(label)
+		if err != nil {
+			continue
+		}
+		if ascii, err := idnaProfile.ToASCII(unicode); err == nil && ascii == strings.ToLower(label) {
+			labels[ix] = unicode
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(value string) bool {
+	for ix := 0; ix < len(value); ix++ {
+		if value[ix] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// ASCIIZone returns a copy of zone with its DNS name in A-label form, which
+// is what providers expect.
+func ASCIIZone(zone Zone) (Zone, error) {
+	dnsName, err := ToASCII(zone.DNSName)
+	if err != nil {
+		return zone, fmt.Errorf("zone %s: dnsName %v", zone.Name, err)
+	}
+	zone.DNSName = dnsName
+	return zone, nil
+}
+
+// UnicodeZone returns a copy of zone with its DNS name converted back to
+// Unicode.
+func UnicodeZone(zone Zone) Zone {
+	zone.DNSName = ToUnicode(zone.DNSName)
+	return zone
+}
+
+// ASCIIRecord returns a copy of record with its name, and its target for
+// CNAME, NS and SOA records, in A-label form.
+func ASCIIRecord(record Record) (Record, error) {
+	result, err := mapNames(record, ToASCII)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v", record.Type(), record.RecordName(), err)
+	}
+	return result, nil
+}
+
+// UnicodeRecord returns a copy of record with its name, and its target for
+// CNAME, NS and SOA records, converted back to Unicode.
+func UnicodeRecord(record Record) Record {
+	result, _ := mapNames(record, func(name string) (string, error) {
+		return ToUnicode(name), nil
+	})
+	return result
+}
+
+// mapNames returns a copy of record with fn applied to every name in it.
+func mapNames(record Record, fn func(string) (string, error)) (Record, error) {
+	name, err := fn(record.RecordName())
+	if err != nil {
+		return nil, err
+	}
+	switch r := record.(type) {
+	case AddressRecord:
+		r.Name = name
+		return r, nil
+	case CNameRecord:
+		r.Name = name
+		if r.CanonicalName, err = fn(r.CanonicalName); err != nil {
+			return nil, err
+		}
+		return r, nil
+	case NSRecord:
+		r.Name = name
+		nameservers := make([]string, len(r.Nameservers))
+		for ix, nameserver := range r.Nameservers {
+			if nameservers[ix], err = fn(nameserver); err != nil {
+				return nil, err
+			}
+		}
+		r.Nameservers = nameservers
+		return r, nil
+	case TXTRecord:
+		r.Name = name
+		return r, nil
+	case SOARecord:
+		r.Name = name
+		if r.Nameserver, err = fn(r.Nameserver); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	return record, nil
+}
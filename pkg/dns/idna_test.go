@@ -0,0 +1,141 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		err      bool
+	}{
+		{name: "www.example.com.", expected: "www.example.com."},
+		{name: "WWW.Example.COM", expected: "www.example.com"},
+		{name: "bücher.example.", expected: "xn--bcher-kva.example."},
+		{name: "BÜCHER.example.", expected: "xn--bcher-kva.example."},
+		{name: "*.bücher.example.", expected: "*.xn--bcher-kva.example."},
+		{name: "_dns-sync.bücher.example.", expected: "_dns-sync.xn--bcher-kva.example."},
+		{name: "xn--bcher-kva.example.", expected: "xn--bcher-kva.example."},
+		{name: "-bücher.example.", err: true},
+	}
+	for _, test := range tests {
+		ascii, err := ToASCII(test.name)
+		if test.err {
+			if err == nil {
+				t.Errorf("expected an error for '%s'", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for '%s': %v", test.name, err)
+		}
+		if ascii != test.expected {
+			t.Errorf("expected %s for '%s', saw %s", test.expected, test.name, ascii)
+		}
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := map[string]string{
+		"xn--bcher-kva.example.":   "bücher.example.",
+		"*.xn--bcher-kva.example.": "*.bücher.example.",
+		"www.example.com.":         "www.example.com.",
+		"xn--invalid-.example.":    "xn--invalid-.example.",
+	}
+	for name, expected := range tests {
+		if unicode := ToUnicode(name); unicode != expected {
+			t.Errorf("expected %s for '%s', saw %s", expected, name, unicode)
+		}
+	}
+}
+
+func TestASCIIRecord(t *testing.T) {
+	record, err := ASCIIRecord(CNameRecord{
+		BaseRecord:    BaseRecord{Name: "www.bücher.example.", TTL: 25},
+		CanonicalName: "www.müller.example.",
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cname := record.(CNameRecord)
+	if cname.Name != "www.xn--bcher-kva.example." || cname.CanonicalName != "www.xn--mller-kva.example." {
+		t.Errorf("unexpected record: %v", cname)
+	}
+	if back := UnicodeRecord(record).(CNameRecord); back.Name != "www.bücher.example." || back.CanonicalName != "www.müller.example." {
+		t.Errorf("unexpected record: %v", back)
+	}
+}
+
+func TestSyncInternationalizedNames(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "bücher.example.",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.bücher.example.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+		NSRecord{
+			BaseRecord: BaseRecord{
+				Name: "sub.bücher.example.",
+				TTL:  25,
+			},
+			Nameservers: []string{"ns1.bücher.example."},
+		},
+	}
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Simulate a provider that only returns A-labels.
+	existing := FakeRecords{}
+	for _, record := range records {
+		ascii, err := ASCIIRecord(record)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		existing[recordKey(ascii)] = ascii
+	}
+	svc.RecordMap[zone.Name] = existing
+	svc.ZoneMap[zone.Name] = Zone{Name: "test", DNSName: "xn--bcher-kva.example."}
+
+	plan, err := Sync(svc, zone, records, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("expected no changes, saw: %v", plan)
+	}
+	if svc.ZoneMap[zone.Name].DNSName != "xn--bcher-kva.example." {
+		t.Errorf("expected the zone to be left alone, saw: %v", svc.ZoneMap[zone.Name])
+	}
+}
+
+func TestValidateInternationalizedNames(t *testing.T) {
+	zone := Zone{Name: "test", DNSName: "bücher.example."}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{Name: "www.bücher.example.", TTL: 25},
+			Addresses:  []string{"1.2.3.4"},
+		},
+	}
+	if err := Validate(zone, records); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	records = append(records, AddressRecord{
+		BaseRecord: BaseRecord{Name: "-bü.bücher.example.", TTL: 25},
+		Addresses:  []string{"1.2.3.4"},
+	})
+	err := Validate(zone, records)
+	validationErr, ok := err.(*ValidationError)
+	if !ok || len(validationErr.Problems) != 1 {
+		t.Errorf("expected one problem, saw: %v", err)
+	}
+}
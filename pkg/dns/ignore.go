@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// IgnoreRule matches records that dns-sync must never create, update or
+// delete. Every field that is set has to match.
+type IgnoreRule struct {
+	// Name is a glob pattern matched against the fully qualified record name,
+	// e.g. "_acme-challenge.*" or "*.k8s.example.com.".
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// NameRegex is a regular expression matched against the fully qualified
+	// record name.
+	NameRegex string `json:"nameRegex,omitempty" yaml:"nameRegex,omitempty"`
+	// Kind is a record type, e.g. "SOA".
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	// Reason is reported when a record is skipped because of this rule.
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+func (r IgnoreRule) String() string {
+	parts := []string{}
+	if len(r.Name) > 0 {
+		parts = append(parts, "name="+r.Name)
+	}
+	if len(r.NameRegex) > 0 {
+		parts = append(parts, "nameRegex="+r.NameRegex)
+	}
+	if len(r.Kind) > 0 {
+		parts = append(parts, "kind="+r.Kind)
+	}
+	return strings.Join(parts, ",")
+}
+
+type ignoreMatcher struct {
+	rule  IgnoreRule
+	regex *regexp.Regexp
+}
+
+func compileIgnoreRules(rules []IgnoreRule) ([]ignoreMatcher, error) {
+	result := make([]ignoreMatcher, len(rules))
+	for ix, rule := range rules {
+		if len(rule.Name) == 0 && len(rule.NameRegex) == 0 && len(rule.Kind) == 0 {
+			return nil, fmt.Errorf("ignore rule %d matches every record", ix)
+		}
+		if len(rule.Name) > 0 {
+			if _, err := path.Match(rule.Name, ""); err != nil {
+				return nil, fmt.Errorf("ignore rule %d: bad name pattern '%s': %v", ix, rule.Name, err)
+			}
+		}
+		result[ix].rule = rule
+		if len(rule.NameRegex) > 0 {
+			regex, err := regexp.Compile(rule.NameRegex)
+			if err != nil {
+				return nil, fmt.Errorf("ignore rule %d: %v", ix, err)
+			}
+			result[ix].regex = regex
+		}
+	}
+	return result, nil
+}
+
+func (m ignoreMatcher) matches(record Record) bool {
+	name := canonicalName(record.RecordName())
+	if len(m.rule.Kind) > 0 && canonicalType(m.rule.Kind) != canonicalType(record.Type()) {
+		return false
+	}
+	if len(m.rule.Name) > 0 {
+		if matched, _ := path.Match(canonicalName(m.rule.Name), name); !matched {
+			return false
+		}
+	}
+	if m.regex != nil && !m.regex.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// ignoreReason returns why a record is ignored, or "" if it isn't.
+func ignoreReason(matchers []ignoreMatcher, record Record) string {
+	for _, matcher := range matchers {
+		if !matcher.matches(record) {
+			continue
+		}
+		if len(matcher.rule.Reason) > 0 {
+			return fmt.Sprintf("ignored (%s): %s", matcher.rule, matcher.rule.Reason)
+		}
+		return fmt.Sprintf("ignored (%s)", matcher.rule)
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package dns
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// includer merges the records of included files into a config. Included files
+// may include further files, but can't declare a zone, variables or defaults;
+// they are expanded with the variables and defaults of the root config.
+type includer struct {
+	variables map[string]string
+	defaults  RecordDefaults
+	// origins maps record keys to the file that defines them.
+	origins map[string]string
+	// loading holds the files currently being included, to detect cycles.
+	loading map[string]bool
+	// included holds every file included so far, by absolute path, so that a
+	// file included twice, e.g. by two files that both include it, is only
+	// merged once.
+	included map[string]bool
+}
+
+func newIncluder(variables map[string]string, defaults RecordDefaults) *includer {
+	return &includer{
+		variables: variables,
+		defaults:  defaults,
+		origins:   map[string]string{},
+		loading:   map[string]bool{},
+		included:  map[string]bool{},
+	}
+}
+
+// include merges every file that config, loaded from file, includes.
+func (i *includer) include(config *Config, file string, data []byte) error {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	i.loading[absolute] = true
+	i.included[absolute] = true
+	defer delete(i.loading, absolute)
+
+	for ix, record := range config.Records {
+		if err := i.claim(record, file, data, ix); err != nil {
+			return err
+		}
+	}
+	for ix, include := range config.Include {
+		path := include
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		files, dir, err := includedFiles(path)
+		if err != nil {
+			return &FieldError{File: file, Line: findLine(data, []interface{}{"include", ix}), Path: []interface{}{"include", ix}, Message: err.Error()}
+		}
+		if dir {
+			config.dirs = append(config.dirs, path)
+		}
+		for _, included := range files {
+			fragment, err := i.load(included)
+			if err != nil {
+				return err
+			}
+			if fragment == nil {
+				continue
+			}
+			config.Records = append(config.Records, fragment.Records...)
+			config.Ignore = append(config.Ignore, fragment.Ignore...)
+			config.files = append(config.files, fragment.files...)
+			config.dirs = append(config.dirs, fragment.dirs...)
+			config.sources = append(config.sources, fragment.sources...)
+		}
+	}
+	return nil
+}
+
+// claim records that file defines record, failing if another file already
+// does. Duplicates within a single file are reported by Validate.
+func (i *includer) claim(record Record, file string, data []byte, ix int) error {
+	key := recordKey(record)
+	if origin, found := i.origins[key]; found && origin != file {
+		path := []interface{}{"records", ix}
+		return &FieldError{
+			File:    file,
+			Line:    findLine(data, path),
+			Path:    path,
+			Message: fmt.Sprintf("%s %s is already defined in %s", record.Type(), record.RecordName(), origin),
+		}
+	}
+	i.origins[key] = file
+	return nil
+}
+
+// load loads an included file and the files it includes. It returns nil if
+// the file was already included.
+func (i *includer) load(file string) (*Config, error) {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if i.loading[absolute] {
+		return nil, fmt.Errorf("%s: include cycle", file)
+	}
+	if i.included[absolute] {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	declared, err := declaredVariables(data)
+	if err != nil {
+		return nil, withFile(err, file)
+	}
+	if len(declared) > 0 {
+		path := []interface{}{"variables"}
+		return nil, &FieldError{File: file, Line: findLine(data, path), Path: path, Message: "variables can only be declared in the root config"}
+	}
+	fragment, err := parseConfig(data, i.variables, &i.defaults)
+	if err != nil {
+		return nil, withFile(err, file)
+	}
+	if fragment.Defaults.TTL != 0 || len(fragment.Defaults.Kinds) > 0 {
+		path := []interface{}{"defaults"}
+		return nil, &FieldError{File: file, Line: findLine(data, path), Path: path, Message: "defaults can only be declared in the root config"}
+	}
+	if len(fragment.Zone.Name) > 0 || len(fragment.Zone.DNSName) > 0 {
+		path := []interface{}{"zone"}
+		return nil, &FieldError{File: file, Line: findLine(data, path), Path: path, Message: "included files can't declare a zone"}
+	}
+	fragment.files = []string{file}
+	fragment.sources = recordSources(file, data, len(fragment.Records))
+	if err := i.include(fragment, file, data); err != nil {
+		return nil, err
+	}
+	return fragment, nil
+}
+
+// includedFiles returns path if it is a file, or the config files in it,
+// sorted by name, if it is a directory, and whether it is one.
+func includedFiles(path string) ([]string, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.IsDir() {
+		return []string{path}, false, nil
+	}
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, true, err
+	}
+	result := []string{}
+	for _, info := range infos {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				result = append(result, filepath.Join(path, info.Name()))
+			}
+		}
+	}
+	sort.Strings(result)
+	return result, true, nil
+}
@@ -0,0 +1,179 @@
+package dns
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return dir
+}
+
+const includeRoot = `variables:
+  ttl: 300
+zone:
+  name: test
+  dnsName: example.com.
+include:
+- teams
+- extra.yaml
+records:
+- kind: A
+  ttl: ${ttl}
+  name: www.example.com.
+  addresses:
+  - 1.2.3.4
+`
+
+func TestLoadConfigIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": includeRoot,
+		"teams/a.yaml": `records:
+- kind: A
+  ttl: ${ttl}
+  name: a.example.com.
+  addresses:
+  - 2.3.4.5
+`,
+		"teams/b.json":    `{"records": [{"kind": "CNAME", "ttl": 60, "name": "b.example.com.", "canonicalName": "a.example.com."}]}`,
+		"teams/README.md": "not a config",
+		"extra.yaml": `include:
+- nested/c.yaml
+ignore:
+- kind: SOA
+`,
+		"nested/c.yaml": `records:
+- kind: TXT
+  ttl: 60
+  name: c.example.com.
+  text:
+  - hello
+`,
+	})
+	config, err := LoadConfig(filepath.Join(dir, "config.yaml"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := []string{}
+	for _, record := range config.Records {
+		names = append(names, record.RecordName())
+	}
+	expected := "www.example.com.,a.example.com.,b.example.com.,c.example.com."
+	if strings.Join(names, ",") != expected {
+		t.Errorf("expected records %s, saw %v", expected, names)
+	}
+	if config.Records[1].TimeToLive() != 300 {
+		t.Errorf("expected variables to be expanded in included files: %v", config.Records[1])
+	}
+	if len(config.Ignore) != 1 {
+		t.Errorf("unexpected ignore rules: %v", config.Ignore)
+	}
+}
+
+func TestLoadConfigIncludesFilesOnce(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `zone:
+  name: test
+  dnsName: example.com.
+include:
+- a.yaml
+- b.yaml
+- shared.yaml
+- ./shared.yaml
+`,
+		"a.yaml": "include:\n- shared.yaml\n",
+		"b.yaml": "include:\n- sub/../shared.yaml\n",
+		"shared.yaml": `records:
+- kind: A
+  ttl: 60
+  name: shared.example.com.
+  addresses:
+  - 1.2.3.4
+`,
+	})
+	config, err := LoadConfig(filepath.Join(dir, "config.yaml"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(config.Records) != 1 || len(config.files) != 4 {
+		t.Errorf("expected shared.yaml to be included once, saw records %v from %v", config.Records, config.files)
+	}
+}
+
+func TestLoadConfigIncludeErrors(t *testing.T) {
+	record := `records:
+- kind: A
+  ttl: 60
+  name: www.example.com.
+  addresses:
+  - 1.2.3.4
+`
+	tests := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{
+			files:    map[string]string{"teams/a.yaml": record, "extra.yaml": ""},
+			expected: "teams/a.yaml:2: records[0]: A www.example.com. is already defined in",
+		},
+		{
+			files:    map[string]string{"teams/a.yaml": "zone:\n  name: other\n  dnsName: other.com.\n", "extra.yaml": ""},
+			expected: "teams/a.yaml:1: zone: included files can't declare a zone",
+		},
+		{
+			files:    map[string]string{"teams/a.yaml": "variables:\n  ttl: 5\n", "extra.yaml": ""},
+			expected: "teams/a.yaml:1: variables: variables can only be declared in the root config",
+		},
+		{
+			files:    map[string]string{"teams/a.yaml": "defaults:\n  ttl: 5m\n", "extra.yaml": ""},
+			expected: "teams/a.yaml:1: defaults: defaults can only be declared in the root config",
+		},
+		{
+			files:    map[string]string{"teams/a.yaml": "records:\n- kind: A\n  name: a.example.com.\n  addresses:\n  - 1.2.3.4\n", "extra.yaml": ""},
+			expected: "teams/a.yaml:2: records[0]: ttl is required",
+		},
+		{
+			files:    map[string]string{"teams/a.yaml": "include:\n- ../config.yaml\n", "extra.yaml": ""},
+			expected: "config.yaml: include cycle",
+		},
+		{
+			files:    map[string]string{"teams/a.yaml": ""},
+			expected: "config.yaml:8: include[1]: stat",
+		},
+		{
+			files:    map[string]string{"teams/a.yaml": "records:\n- kind: A\n  tll: 60\n", "extra.yaml": ""},
+			expected: "teams/a.yaml:3: records[0].tll: unknown field",
+		},
+	}
+	for ix, test := range tests {
+		test.files["config.yaml"] = includeRoot
+		dir := writeFiles(t, test.files)
+		_, err := LoadConfig(filepath.Join(dir, "config.yaml"), nil)
+		if err == nil {
+			t.Errorf("[%d] expected an error", ix)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("[%d] expected '%s' in '%s'", ix, test.expected, err.Error())
+		}
+	}
+}
+
+func TestParseConfigRejectsIncludes(t *testing.T) {
+	if _, err := ParseConfig([]byte(includeRoot), nil); err == nil {
+		t.Errorf("expected an error")
+	}
+}
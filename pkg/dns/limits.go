@@ -0,0 +1,75 @@
+package dns
+
+import (
+	"fmt"
+)
+
+// Limits guards against plans that change a large part of a zone, which
+// usually means the config is empty or truncated, or the provider returned
+// records that the config doesn't know about. Zero values mean no limit.
+type Limits struct {
+	// MaxDeletes is the maximum number of records that may be deleted.
+	MaxDeletes int
+	// MaxDeletePercent is the maximum percentage of the existing records that
+	// may be deleted.
+	MaxDeletePercent float64
+	// MaxChanges is the maximum number of creates, updates and deletes.
+	MaxChanges int
+	// MaxChangePercent is the maximum number of changes as a percentage of
+	// the existing records.
+	MaxChangePercent float64
+}
+
+// LimitError is returned when a plan exceeds the configured limits.
+type LimitError struct {
+	Reason string
+}
+
+func (l *LimitError) Error() string {
+	return fmt.Sprintf("refusing to sync, %s (use force to override)", l.Reason)
+}
+
+// check returns a *LimitError if the plan exceeds the limits. Percentages are
+// relative to the zone's records other than the SOA record, which every zone
+// has, and are not checked against an empty zone. Ownership records are left
+// out of every count, since they come and go with the records they mark.
+func (l Limits) check(plan *Plan) error {
+	existing := 0
+	for _, record := range plan.Existing {
+		if canonicalType(record.Type()) != "SOA" && !isOwnerRecord(record) {
+			existing++
+		}
+	}
+	deletes, changes := 0, 0
+	for _, change := range plan.Changes {
+		if isOwnerRecord(change.record()) {
+			continue
+		}
+		changes++
+		if change.Action == ActionDelete {
+			deletes++
+		}
+	}
+	return l.checkCounts(deletes, changes, existing)
+}
+
+// checkCounts returns a *LimitError if deleting deletes and making changes
+// changes out of existing records exceeds the limits.
+func (l Limits) checkCounts(deletes, changes, existing int) error {
+	if l.MaxDeletes > 0 && deletes > l.MaxDeletes {
+		return &LimitError{fmt.Sprintf("%d deletions exceed the limit of %d", deletes, l.MaxDeletes)}
+	}
+	if l.MaxChanges > 0 && changes > l.MaxChanges {
+		return &LimitError{fmt.Sprintf("%d changes exceed the limit of %d", changes, l.MaxChanges)}
+	}
+	if existing == 0 {
+		return nil
+	}
+	if percent := 100 * float64(deletes) / float64(existing); l.MaxDeletePercent > 0 && percent > l.MaxDeletePercent {
+		return &LimitError{fmt.Sprintf("deleting %.0f%% of %d records exceeds the limit of %.0f%%", percent, existing, l.MaxDeletePercent)}
+	}
+	if percent := 100 * float64(changes) / float64(existing); l.MaxChangePercent > 0 && percent > l.MaxChangePercent {
+		return &LimitError{fmt.Sprintf("changing %.0f%% of %d records exceeds the limit of %.0f%%", percent, existing, l.MaxChangePercent)}
+	}
+	return nil
+}
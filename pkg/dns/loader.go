@@ -1,57 +1,485 @@
 package dns
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/ghodss/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
 )
 
+// FieldError is a problem with a single field of a config file. Path locates
+// the field, e.g. ["records", 2, "addresses"], and File and Line are filled in
+// when the config is loaded from a file.
+type FieldError struct {
+	File    string
+	Line    int
+	Path    []interface{}
+	Message string
+}
+
+func (f *FieldError) Error() string {
+	location := ""
+	if len(f.File) > 0 {
+		location = f.File + ":"
+	}
+	if f.Line > 0 {
+		location += strconv.Itoa(f.Line) + ":"
+	}
+	if len(location) > 0 {
+		location += " "
+	}
+	return fmt.Sprintf("%s%s: %s", location, formatPath(f.Path), f.Message)
+}
+
+func formatPath(path []interface{}) string {
+	if len(path) == 0 {
+		return "config"
+	}
+	result := ""
+	for _, elem := range path {
+		switch elem := elem.(type) {
+		case int:
+			result += fmt.Sprintf("[%d]", elem)
+		default:
+			if len(result) > 0 {
+				result += "."
+			}
+			result += fmt.Sprintf("%v", elem)
+		}
+	}
+	return result
+}
+
+func childPath(path []interface{}, elems ...interface{}) []interface{} {
+	result := make([]interface{}, 0, len(path)+len(elems))
+	return append(append(result, path...), elems...)
+}
+
+// configFields are the top level fields of a config.
+var configFields = map[string]bool{
+	"zone":      true,
+	"records":   true,
+	"ignore":    true,
+	"variables": true,
+	"include":   true,
+	"defaults":  true,
+}
+
+// requiredFields lists the fields that a record of each kind must set, in
+// addition to kind and name. ttl is required unless there is a default.
+var requiredFields = map[string][]string{
+	"A":     {"addresses"},
+	"CNAME": {"canonicalName"},
+	"NS":    {"nameservers"},
+	"TXT":   {"text"},
+}
+
+// LoadConfig reads and parses a YAML or JSON config file, along with any
+// files it includes. overrides sets variables declared in the config, taking
+// precedence over the environment.
+func LoadConfig(file string, overrides map[string]string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := configVariables(data, overrides)
+	if err != nil {
+		return nil, withFile(err, file)
+	}
+	config, err := parseConfig(data, variables, nil)
+	if err != nil {
+		return nil, withFile(err, file)
+	}
+	config.Variables = variables
+	config.files = []string{file}
+	config.sources = recordSources(file, data, len(config.Records))
+	if err := newIncluder(variables, config.Defaults).include(config, file, data); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func recordSources(file string, data []byte, count int) []recordSource {
+	result := make([]recordSource, count)
+	for ix := range result {
+		result[ix] = recordSource{File: file, Line: findLine(data, []interface{}{"records", ix})}
+	}
+	return result
+}
+
+// ParseConfig parses a YAML or JSON config, expanding variables first.
+// Unknown fields and missing required fields are errors, reported as a
+// *FieldError with the line number of the offending field. Configs that
+// include other files have to be loaded with LoadConfig.
+func ParseConfig(data []byte, overrides map[string]string) (*Config, error) {
+	variables, err := configVariables(data, overrides)
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseConfig(data, variables, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Include) > 0 {
+		return nil, &FieldError{Path: []interface{}{"include"}, Line: findLine(data, []interface{}{"include"}), Message: "includes require loading the config from a file"}
+	}
+	config.Variables = variables
+	return config, nil
+}
+
+// parseConfig parses data, expanding variables, and applies defaults to its
+// records. If defaults is nil, the config's own defaults are used.
+func parseConfig(data []byte, variables map[string]string, defaults *RecordDefaults) (*Config, error) {
+	expanded, err := expandVariables(data, variables)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := yaml.YAMLToJSON(expanded)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(jsonData, config); err != nil {
+		if fieldErr, ok := err.(*FieldError); ok {
+			fieldErr.Line = findLine(data, fieldErr.Path)
+		}
+		return nil, err
+	}
+	if defaults == nil {
+		defaults = &config.Defaults
+	}
+	if err := applyDefaults(config, *defaults); err != nil {
+		if fieldErr, ok := err.(*FieldError); ok {
+			fieldErr.Line = findLine(data, fieldErr.Path)
+		}
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyDefaults sets the ttl of every record that doesn't have one, e.g. the
+// records of an included file, which use the root config's defaults.
+func applyDefaults(config *Config, defaults RecordDefaults) error {
+	for _, ix := range config.missingTTL {
+		ttl := defaults.ttlFor(config.Records[ix].Type())
+		if ttl == 0 {
+			return &FieldError{Path: []interface{}{"records", ix}, Message: "ttl is required, set it or a default ttl"}
+		}
+		config.Records[ix] = withTTL(config.Records[ix], ttl)
+	}
+	config.missingTTL = nil
+	return nil
+}
+
+// withFile adds the name of the file that caused an error.
+func withFile(err error, file string) error {
+	if fieldErr, ok := err.(*FieldError); ok {
+		fieldErr.File = file
+		return fieldErr
+	}
+	return fmt.Errorf("%s: %v", file, err)
+}
+
+// UnmarshalJSON decodes a config and applies its defaults to its records.
 func (c *Config) UnmarshalJSON(b []byte) error {
 	var objMap map[string]*json.RawMessage
-	err := json.Unmarshal(b, &objMap)
-	if err != nil {
-		return err
+	if err := json.Unmarshal(b, &objMap); err != nil {
+		return &FieldError{Message: "expected an object"}
+	}
+	for key := range objMap {
+		if !configFields[key] {
+			return &FieldError{Path: []interface{}{key}, Message: "unknown field"}
+		}
 	}
+
 	zoneMessage, exists := objMap["zone"]
-	if exists {
-		if err := json.Unmarshal(*zoneMessage, &c.Zone); err != nil {
+	if exists && zoneMessage != nil {
+		path := []interface{}{"zone"}
+		if soa := childMessage(*zoneMessage, "soa"); soa != nil {
+			if err := checkTTLs(soa, childPath(path, "soa"), "refresh", "retry", "expire", "negativeTTL", "ttl"); err != nil {
+				return err
+			}
+		}
+		if err := decodeStrict(*zoneMessage, &c.Zone, path); err != nil {
+			return err
+		}
+		if err := checkRequired(*zoneMessage, path, "name", "dnsName"); err != nil {
 			return err
 		}
 	}
 
+	ignoreMessage, exists := objMap["ignore"]
+	if exists && ignoreMessage != nil {
+		var ignoreMessages []json.RawMessage
+		if err := json.Unmarshal(*ignoreMessage, &ignoreMessages); err != nil {
+			return &FieldError{Path: []interface{}{"ignore"}, Message: "expected a list"}
+		}
+		c.Ignore = make([]IgnoreRule, len(ignoreMessages))
+		for ix, msg := range ignoreMessages {
+			if err := decodeStrict(msg, &c.Ignore[ix], []interface{}{"ignore", ix}); err != nil {
+				return err
+			}
+		}
+	}
+
+	defaultsMessage, exists := objMap["defaults"]
+	if exists && defaultsMessage != nil {
+		path := []interface{}{"defaults"}
+		if err := checkTTLs(*defaultsMessage, path, "ttl"); err != nil {
+			return err
+		}
+		kindMessages := map[string]json.RawMessage{}
+		if kinds := childMessage(*defaultsMessage, "kinds"); kinds != nil && json.Unmarshal(kinds, &kindMessages) == nil {
+			for _, kind := range sortedKeys(kindMessages) {
+				if err := checkTTLs(kindMessages[kind], childPath(path, "kinds", kind), "ttl"); err != nil {
+					return err
+				}
+			}
+		}
+		if err := decodeStrict(*defaultsMessage, &c.Defaults, path); err != nil {
+			return err
+		}
+		kinds := map[string]KindDefaults{}
+		for _, kind := range sortedKindKeys(c.Defaults.Kinds) {
+			canonical := canonicalType(kind)
+			if _, found := requiredFields[canonical]; !found {
+				return &FieldError{Path: childPath(path, "kinds", kind), Message: fmt.Sprintf("unknown record type: %s", kind)}
+			}
+			if _, found := kinds[canonical]; found {
+				return &FieldError{Path: childPath(path, "kinds", kind), Message: fmt.Sprintf("defaults for %s are already set", canonical)}
+			}
+			kinds[canonical] = c.Defaults.Kinds[kind]
+		}
+		if len(kinds) > 0 {
+			c.Defaults.Kinds = kinds
+		}
+	}
+
+	includeMessage, exists := objMap["include"]
+	if exists && includeMessage != nil {
+		if err := json.Unmarshal(*includeMessage, &c.Include); err != nil {
+			return &FieldError{Path: []interface{}{"include"}, Message: "expected a list of paths"}
+		}
+	}
+
 	recordMessage, exists := objMap["records"]
 	if !exists || recordMessage == nil {
 		return nil
 	}
-	var recordMessages []*json.RawMessage
+	var recordMessages []json.RawMessage
 	if err := json.Unmarshal(*recordMessage, &recordMessages); err != nil {
-		return err
+		return &FieldError{Path: []interface{}{"records"}, Message: "expected a list"}
 	}
 
 	c.Records = make([]Record, len(recordMessages))
-
 	for ix, msg := range recordMessages {
-		obj := map[string]interface{}{}
-		if err := json.Unmarshal(*msg, &obj); err != nil {
+		record, err := decodeRecord(msg, []interface{}{"records", ix})
+		if err != nil {
 			return err
 		}
-		kind := strings.ToUpper(obj["kind"].(string))
-		switch kind {
-		case "A":
-			record := AddressRecord{}
-			json.Unmarshal(*msg, &record)
-			c.Records[ix] = record
-		case "NS":
-			record := NSRecord{}
-			json.Unmarshal(*msg, &record)
-			c.Records[ix] = record
-		case "CNAME":
-			record := CNameRecord{}
-			json.Unmarshal(*msg, &record)
-			c.Records[ix] = record
-		default:
-			return fmt.Errorf("Unknown record type: %v", kind)
+		if !hasField(msg, "ttl") {
+			if ttl := c.Defaults.ttlFor(record.Type()); ttl > 0 {
+				record = withTTL(record, ttl)
+			} else {
+				c.missingTTL = append(c.missingTTL, ix)
+			}
 		}
+		c.Records[ix] = record
 	}
 	return nil
 }
+
+func decodeRecord(msg json.RawMessage, path []interface{}) (Record, error) {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(msg, &obj); err != nil {
+		return nil, &FieldError{Path: path, Message: "expected an object"}
+	}
+	kindValue, found := obj["kind"]
+	if !found {
+		return nil, &FieldError{Path: path, Message: "kind is required"}
+	}
+	kindString, ok := kindValue.(string)
+	if !ok {
+		return nil, &FieldError{Path: childPath(path, "kind"), Message: "expected a string"}
+	}
+	kind := canonicalType(kindString)
+	if err := checkTTLs(msg, path, "ttl"); err != nil {
+		return nil, err
+	}
+
+	var record Record
+	var err error
+	switch kind {
+	case "A":
+		address := AddressRecord{}
+		err = decodeStrict(msg, &address, path)
+		record = address
+	case "NS":
+		ns := NSRecord{}
+		err = decodeStrict(msg, &ns, path)
+		record = ns
+	case "CNAME":
+		cname := CNameRecord{}
+		err = decodeStrict(msg, &cname, path)
+		record = cname
+	case "TXT":
+		txt := TXTRecord{}
+		err = decodeStrict(msg, &txt, path)
+		record = txt
+	default:
+		return nil, &FieldError{Path: childPath(path, "kind"), Message: fmt.Sprintf("unknown record type: %s", kindString)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRequired(msg, path, append([]string{"name"}, requiredFields[kind]...)...); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// decodeStrict decodes msg into obj, rejecting unknown fields.
+func decodeStrict(msg json.RawMessage, obj interface{}, path []interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(msg))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(obj)
+	if err == nil {
+		return nil
+	}
+	if ttlErr, ok := err.(*ttlError); ok {
+		return &FieldError{Path: path, Message: ttlErr.Error()}
+	}
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		fieldPath := path
+		if len(typeErr.Field) > 0 {
+			for _, field := range strings.Split(typeErr.Field, ".") {
+				fieldPath = childPath(fieldPath, field)
+			}
+		}
+		return &FieldError{Path: fieldPath, Message: fmt.Sprintf("expected %v, got %s", typeErr.Type, typeErr.Value)}
+	}
+	if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+		if unquoted, err := strconv.Unquote(field); err == nil {
+			field = unquoted
+		}
+		return &FieldError{Path: childPath(path, field), Message: "unknown field"}
+	}
+	return &FieldError{Path: path, Message: err.Error()}
+}
+
+func sortedKindKeys(values map[string]KindDefaults) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(values map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func checkRequired(msg json.RawMessage, path []interface{}, fields ...string) error {
+	obj := map[string]*json.RawMessage{}
+	if err := json.Unmarshal(msg, &obj); err != nil {
+		return &FieldError{Path: path, Message: "expected an object"}
+	}
+	for _, field := range fields {
+		if value, found := obj[field]; !found || value == nil {
+			return &FieldError{Path: path, Message: fmt.Sprintf("%s is required", field)}
+		}
+	}
+	return nil
+}
+
+// checkTTLs parses the ttl fields of msg, so that an invalid one is reported
+// with its path. TTL.UnmarshalJSON doesn't know which field it decodes.
+func checkTTLs(msg json.RawMessage, path []interface{}, fields ...string) error {
+	for _, field := range fields {
+		value := childMessage(msg, field)
+		if value == nil {
+			continue
+		}
+		var ttl TTL
+		if err := json.Unmarshal(value, &ttl); err != nil {
+			return &FieldError{Path: childPath(path, field), Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// childMessage returns the value of field in msg, or nil if msg isn't an
+// object or the field isn't set.
+func childMessage(msg json.RawMessage, field string) json.RawMessage {
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(msg, &obj); err != nil {
+		return nil
+	}
+	if value := obj[field]; string(value) != "null" {
+		return value
+	}
+	return nil
+}
+
+func hasField(msg json.RawMessage, field string) bool {
+	obj := map[string]*json.RawMessage{}
+	if err := json.Unmarshal(msg, &obj); err != nil {
+		return false
+	}
+	value, found := obj[field]
+	return found && value != nil
+}
+
+// findLine returns the line of the YAML node at path in data, or of its
+// closest ancestor if the path doesn't exist, e.g. for a missing field.
+func findLine(data []byte, path []interface{}) int {
+	node, err := parseTemplate(data)
+	if err != nil {
+		return 0
+	}
+	if node.Kind == yamlv3.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := node.Line
+	for _, elem := range path {
+		for node.Kind == yamlv3.AliasNode && node.Alias != nil {
+			node = node.Alias
+		}
+		var next *yamlv3.Node
+		switch elem := elem.(type) {
+		case int:
+			if node.Kind == yamlv3.SequenceNode && elem < len(node.Content) {
+				next = node.Content[elem]
+				line = next.Line
+			}
+		case string:
+			if node.Kind != yamlv3.MappingNode {
+				break
+			}
+			for ix := 0; ix+1 < len(node.Content); ix += 2 {
+				if node.Content[ix].Value == elem {
+					next = node.Content[ix+1]
+					line = node.Content[ix].Line
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line
+}
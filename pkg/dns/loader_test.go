@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+const validConfig = `zone:
+  name: test
+  dnsName: sync.contuso.io.
+  description: this is an example
+records:
+- kind: A
+  ttl: 350
+  name: www.sync.contuso.io.
+  addresses:
+  - 1.2.3.4
+  - 2.3.4.5
+- kind: cname
+  ttl: 200
+  name: cname.sync.contuso.io.
+  canonicalName: foobar.example.com.
+- kind: TXT
+  ttl: 200
+  name: txt.sync.contuso.io.
+  text:
+  - hello
+ignore:
+- name: _acme-challenge.*
+  reason: cert-manager
+`
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(validConfig), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Zone.Name != "test" || config.Zone.DNSName != "sync.contuso.io." {
+		t.Errorf("unexpected zone: %v", config.Zone)
+	}
+	if len(config.Records) != 3 {
+		t.Fatalf("unexpected records: %v", config.Records)
+	}
+	if record, ok := config.Records[0].(AddressRecord); !ok || len(record.Addresses) != 2 || record.TTL != 350 {
+		t.Errorf("unexpected record: %v", config.Records[0])
+	}
+	if record, ok := config.Records[1].(CNameRecord); !ok || record.CanonicalName != "foobar.example.com." || record.Type() != "CNAME" {
+		t.Errorf("unexpected record: %v", config.Records[1])
+	}
+	if record, ok := config.Records[2].(TXTRecord); !ok || record.Text[0] != "hello" {
+		t.Errorf("unexpected record: %v", config.Records[2])
+	}
+	if len(config.Ignore) != 1 || config.Ignore[0].Reason != "cert-manager" {
+		t.Errorf("unexpected ignore rules: %v", config.Ignore)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected string
+	}{
+		{
+			config:   strings.Replace(validConfig, "  addresses:", "  adresses:", 1),
+			expected: "9: records[0].adresses: unknown field",
+		},
+		{
+			config:   strings.Replace(validConfig, "- kind: cname\n  ttl", "- ttl", 1),
+			expected: "12: records[1]: kind is required",
+		},
+		{
+			config:   strings.Replace(validConfig, "kind: cname", "kind: MX", 1),
+			expected: "12: records[1].kind: unknown record type: MX",
+		},
+		{
+			config:   strings.Replace(validConfig, "kind: cname", "kind: 5", 1),
+			expected: "12: records[1].kind: expected a string",
+		},
+		{
+			config:   strings.Replace(validConfig, "  canonicalName: foobar.example.com.\n", "", 1),
+			expected: "12: records[1]: canonicalName is required",
+		},
+		{
+			config:   strings.Replace(validConfig, "ttl: 200", "ttl: soon", 1),
+			expected: "13: records[1].ttl: invalid ttl: soon",
+		},
+		{
+			config:   strings.Replace(validConfig, "  description: this is an example\n", "  soa:\n    retry: 5m\n    refresh: soon\n", 1),
+			expected: "6: zone.soa.refresh: invalid ttl: soon",
+		},
+		{
+			config:   strings.Replace(validConfig, "  dnsName: sync.contuso.io.\n", "", 1),
+			expected: "1: zone: dnsName is required",
+		},
+		{
+			config:   strings.Replace(validConfig, "  description:", "  descripton:", 1),
+			expected: "4: zone.descripton: unknown field",
+		},
+		{
+			config:   validConfig + "recrods: []\n",
+			expected: "24: recrods: unknown field",
+		},
+		{
+			config:   strings.Replace(validConfig, "  reason:", "  because:", 1),
+			expected: "23: ignore[0].because: unknown field",
+		},
+	}
+	for ix, test := range tests {
+		_, err := ParseConfig([]byte(test.config), nil)
+		if err == nil {
+			t.Errorf("[%d] expected an error", ix)
+			continue
+		}
+		if _, ok := err.(*FieldError); !ok {
+			t.Errorf("[%d] expected a field error, saw: %v", ix, err)
+		}
+		if err.Error() != test.expected {
+			t.Errorf("[%d] expected '%s', saw '%s'", ix, test.expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package dns
+
+import (
+	"sort"
+)
+
+// orderChanges sorts changes so that each one can be applied once the
+// changes before it have been made, and numbers the stages. Changes in the
+// same stage don't depend on each other. The stages are, in order:
+//
+//   - creates and updates of ownership records, so that a record is never
+//     created without one
+//   - deletes of records that conflict with a record being created at the
+//     same name, e.g. an A record that is being replaced by a CNAME
+//   - creates and updates of ordinary records, which CNAMEs may point at
+//   - creates and updates of CNAMEs, with CNAMEs that point at other changed
+//     CNAMEs after their targets
+//   - creates and updates of delegations (NS records)
+//   - all other deletes
+//   - deletes of delegations
+//   - deletes of ownership records, once the records they mark are gone
+func orderChanges(changes []Change) []Change {
+	aliases := map[string]Change{}
+	for _, change := range changes {
+		if change.Action != ActionDelete && change.Desired.Type() == "CNAME" {
+			aliases[canonicalName(change.Desired.RecordName())] = change
+		}
+	}
+	// findAlias returns the changed CNAME that answers for name, which may
+	// be the closest wildcard.
+	findAlias := func(name string) (Change, string, bool) {
+		if alias, found := aliases[name]; found {
+			return alias, name, true
+		}
+		closest := ""
+		for aliasName := range aliases {
+			if wildcardMatches(aliasName, name) && len(aliasName) > len(closest) {
+				closest = aliasName
+			}
+		}
+		return aliases[closest], closest, len(closest) > 0
+	}
+	depth := func(change Change) int {
+		result := 0
+		seen := map[string]bool{canonicalName(change.Desired.RecordName()): true}
+		for {
+			next, name, found := findAlias(canonicalName(change.Desired.RRData()[0]))
+			if !found || seen[name] {
+				return result
+			}
+			seen[name] = true
+			result++
+			change = next
+		}
+	}
+	maxDepth := 0
+	for _, change := range aliases {
+		if d := depth(change); d > maxDepth {
+			maxDepth = d
+		}
+	}
+
+	const (
+		stageOwners = iota
+		stageUnblock
+		stageRecords
+		stageAliases
+	)
+	stageDelegations := stageAliases + maxDepth + 1
+	stageDeletes := stageDelegations + 1
+	stageDelegationDeletes := stageDeletes + 1
+	stageOwnerDeletes := stageDelegationDeletes + 1
+
+	result := make([]Change, len(changes))
+	copy(result, changes)
+	for ix := range result {
+		change := &result[ix]
+		switch {
+		case change.Action == ActionDelete && isOwnerRecord(change.Existing):
+			change.Stage = stageOwnerDeletes
+		case change.Action != ActionDelete && isOwnerRecord(change.Desired):
+			change.Stage = stageOwners
+		case change.Action == ActionDelete && blocksCreate(change.Existing, changes):
+			change.Stage = stageUnblock
+		case change.Action == ActionDelete && change.Existing.Type() == "NS":
+			change.Stage = stageDelegationDeletes
+		case change.Action == ActionDelete:
+			change.Stage = stageDeletes
+		case change.Desired.Type() == "CNAME":
+			change.Stage = stageAliases + depth(*change)
+		case change.Desired.Type() == "NS":
+			change.Stage = stageDelegations
+		default:
+			change.Stage = stageRecords
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Stage < result[j].Stage
+	})
+
+	// Renumber the stages so that they are consecutive.
+	stage, last := -1, -1
+	for ix := range result {
+		if result[ix].Stage != last {
+			last = result[ix].Stage
+			stage++
+		}
+		result[ix].Stage = stage
+	}
+	return result
+}
+
+// blocksCreate returns true if record has to be deleted before one of the
+// changes can create a record at the same name. A CNAME can't coexist with
+// any other record.
+func blocksCreate(record Record, changes []Change) bool {
+	name := canonicalName(record.RecordName())
+	for _, change := range changes {
+		if change.Action != ActionCreate || canonicalName(change.Desired.RecordName()) != name {
+			continue
+		}
+		if change.Desired.Type() == "CNAME" || record.Type() == "CNAME" {
+			return true
+		}
+	}
+	return false
+}
+
+// dependsOn returns true if change can't be made correctly unless unmade, a
+// change in an earlier stage, was made: the ownership record marking the same
+// name, a delete of a record blocking it from being created, or a record its
+// CNAME points at.
+func dependsOn(change, unmade Change) bool {
+	record, other := change.record(), unmade.record()
+	name, otherName := canonicalName(record.RecordName()), canonicalName(other.RecordName())
+	switch {
+	case isOwnerRecord(record) != isOwnerRecord(other):
+		if (change.Action == ActionDelete) != (unmade.Action == ActionDelete) {
+			return false
+		}
+		if isOwnerRecord(record) {
+			return ownedName(record) == otherName
+		}
+		return ownedName(other) == name
+	case change.Action == ActionDelete:
+		return false
+	case unmade.Action == ActionDelete:
+		return blocksCreate(unmade.Existing, []Change{change})
+	case change.Desired.Type() == "CNAME":
+		target := canonicalName(change.Desired.RRData()[0])
+		return target == otherName || wildcardMatches(otherName, target)
+	}
+	return false
+}
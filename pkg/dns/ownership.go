@@ -0,0 +1,107 @@
+package dns
+
+import (
+	"strings"
+)
+
+// dns-sync marks the records it manages with a companion TXT record that
+// names the owner, e.g. _dns-sync.www.example.com. for www.example.com. When
+// an owner ID is configured, Sync refuses to overwrite records owned by
+// someone else and only deletes records that it owns. A wildcard can't
+// appear after the prefix, so the companion of *.example.com. is
+// _dns-sync._wildcard.example.com.
+const (
+	ownerRecordPrefix = "_dns-sync."
+	ownerWildcard     = "_wildcard."
+	ownerRecordTTL    = 300
+	ownerHeritage     = "heritage=dns-sync"
+	ownerKey          = "dns-sync/owner="
+)
+
+func isOwnerRecord(record Record) bool {
+	return canonicalType(record.Type()) == "TXT" &&
+		strings.HasPrefix(canonicalName(record.RecordName()), ownerRecordPrefix)
+}
+
+func ownerRecordName(name string) string {
+	name = canonicalName(name)
+	if isWildcard(name) {
+		name = ownerWildcard + name[2:]
+	}
+	return ownerRecordPrefix + name
+}
+
+// ownedName returns the name of the record that an owner record marks.
+func ownedName(ownerRecord Record) string {
+	name := strings.TrimPrefix(canonicalName(ownerRecord.RecordName()), ownerRecordPrefix)
+	if strings.HasPrefix(name, ownerWildcard) {
+		name = "*." + strings.TrimPrefix(name, ownerWildcard)
+	}
+	return name
+}
+
+func ownerText(owner string) string {
+	return ownerHeritage + "," + ownerKey + owner
+}
+
+// parseOwner returns the owner named in a TXT record, or "" if the record
+// wasn't written by dns-sync.
+func parseOwner(record Record) string {
+	for _, text := range record.CanonicalRRData() {
+		fields := strings.Split(text, ",")
+		if len(fields) < 2 || fields[0] != ownerHeritage {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, ownerKey) {
+				return strings.TrimPrefix(field, ownerKey)
+			}
+		}
+	}
+	return ""
+}
+
+// ownerMap maps record names to the owner recorded in their companion record.
+type ownerMap map[string]string
+
+func recordOwners(records []Record) ownerMap {
+	owners := ownerMap{}
+	for _, record := range records {
+		if !isOwnerRecord(record) {
+			continue
+		}
+		owners[ownedName(record)] = parseOwner(record)
+	}
+	return owners
+}
+
+// ownerOf returns the owner of a record, or "" if it is unowned. Companion
+// records are owned by the owner they name.
+func (o ownerMap) ownerOf(record Record) string {
+	if isOwnerRecord(record) {
+		return parseOwner(record)
+	}
+	return o[canonicalName(record.RecordName())]
+}
+
+// ownerRecords returns a companion record for each distinct name in records.
+func ownerRecords(records []Record, owner string) []Record {
+	result := []Record{}
+	seen := map[string]bool{}
+	for _, record := range records {
+		name := canonicalName(record.RecordName())
+		if seen[name] || isOwnerRecord(record) {
+			continue
+		}
+		seen[name] = true
+		result = append(result, TXTRecord{
+			BaseRecord: BaseRecord{
+				Name: ownerRecordName(name),
+				TTL:  ownerRecordTTL,
+				Kind: "TXT",
+			},
+			Text: []string{ownerText(owner)},
+		})
+	}
+	return result
+}
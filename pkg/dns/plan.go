@@ -0,0 +1,352 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+type ChangeAction string
+
+const (
+	ActionCreate ChangeAction = "create"
+	ActionUpdate ChangeAction = "update"
+	ActionDelete ChangeAction = "delete"
+)
+
+// Change is a single record set operation against a zone.
+type Change struct {
+	Action ChangeAction
+	// Existing is the live record, nil for creates.
+	Existing Record
+	// Desired is the record from the config, nil for deletes.
+	Desired Record
+	// Stage orders the changes. Every change in a stage is applied after
+	// all of the changes in earlier stages.
+	Stage int
+}
+
+func (c Change) record() Record {
+	if c.Desired != nil {
+		return c.Desired
+	}
+	return c.Existing
+}
+
+func (c Change) String() string {
+	record := c.record()
+	return fmt.Sprintf("[%d] %s %s %s", c.Stage, c.Action, record.Type(), record.RecordName())
+}
+
+// Diff describes the change along with the record data before and after it,
+// e.g. "update A www.example.com.: [1.2.3.4] -> [5.6.7.8]".
+func (c Change) Diff() string {
+	record := c.record()
+	before, after := []string{}, []string{}
+	if c.Existing != nil {
+		before = c.Existing.RRData()
+	}
+	if c.Desired != nil {
+		after = c.Desired.RRData()
+	}
+	return fmt.Sprintf("%s %s %s: %v -> %v", c.Action, record.Type(), record.RecordName(), before, after)
+}
+
+// Skip is a record that Sync deliberately left alone.
+type Skip struct {
+	Record Record
+	Reason string
+}
+
+func (s Skip) String() string {
+	return fmt.Sprintf("skip %s %s: %s", s.Record.Type(), s.Record.RecordName(), s.Reason)
+}
+
+// Plan is the set of changes needed to make a zone match the config, along
+// with the records that were skipped and why.
+type Plan struct {
+	Zone    Zone
+	Changes []Change
+	Skipped []Skip
+	// Existing are the live records the plan was computed against, and
+	// CreateZone is set if the zone didn't exist yet.
+	Existing   []Record
+	CreateZone bool
+}
+
+func (p *Plan) skip(record Record, format string, args ...interface{}) {
+	skip := Skip{Record: record, Reason: fmt.Sprintf(format, args...)}
+	glog.V(2).Info(skip)
+	p.Skipped = append(p.Skipped, skip)
+}
+
+// String returns a human readable description of the plan, one line per
+// change or skipped record.
+func (p *Plan) String() string {
+	lines := []string{}
+	for _, change := range p.Changes {
+		lines = append(lines, change.String())
+	}
+	for _, skip := range p.Skipped {
+		lines = append(lines, skip.String())
+	}
+	if len(lines) == 0 {
+		return "no changes"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Drift describes every difference between the config and the live zone that
+// the plan would fix, starting with the zone itself if it doesn't exist.
+func (p *Plan) Drift() []string {
+	drift := []string{}
+	if p.CreateZone {
+		drift = append(drift, fmt.Sprintf("create zone %s (%s)", p.Zone.Name, p.Zone.DNSName))
+	}
+	for _, change := range p.Changes {
+		drift = append(drift, change.Diff())
+	}
+	return drift
+}
+
+// ConflictError is returned when the config wants to change records that are
+// owned by another owner.
+type ConflictError struct {
+	Conflicts []string
+}
+
+func (c *ConflictError) Error() string {
+	return fmt.Sprintf("records are owned by another owner: %s", strings.Join(c.Conflicts, ", "))
+}
+
+// computePlan compares the existing records in a zone with the desired records
+// and returns the changes that options allow. nameservers are the zone's
+// nameservers, which are never removed from the apex NS record.
+func computePlan(zone Zone, existingRecords, records []Record, nameservers []string, options Options) (*Plan, error) {
+	plan := &Plan{Zone: zone, Existing: existingRecords}
+	ignored, err := compileIgnoreRules(options.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	records = withZoneNameservers(zone, records, nameservers)
+	owners := recordOwners(existingRecords)
+	existingByKey := recordsByKey(existingRecords)
+
+	conflicts := []string{}
+	// planDesired plans the change, if any, that makes the zone match record,
+	// and returns true if the record will be in the zone as desired.
+	planDesired := func(record Record) bool {
+		if reason := ignoreReason(ignored, record); len(reason) > 0 {
+			plan.skip(record, "%s", reason)
+			return false
+		}
+		existingRecord, found := existingByKey[recordKey(record)]
+		if !found {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Desired: record})
+			return true
+		}
+		if reason := ignoreReason(ignored, existingRecord); len(reason) > 0 {
+			plan.skip(existingRecord, "%s", reason)
+			return false
+		}
+		if owner := owners.ownerOf(existingRecord); len(owner) > 0 && owner != options.OwnerID {
+			if len(options.OwnerID) > 0 {
+				conflicts = append(conflicts, fmt.Sprintf("%s (%s)", record.RecordName(), owner))
+			} else {
+				plan.skip(existingRecord, "owned by '%s'", owner)
+			}
+			return false
+		}
+		if !recordIsDifferent(record, existingRecord) {
+			return true
+		}
+		if !options.Policy.allowsUpdate() {
+			plan.skip(existingRecord, "policy %s doesn't allow updates", options.Policy)
+			return false
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Existing: existingRecord, Desired: record})
+		return true
+	}
+	managed := []Record{}
+	for _, record := range records {
+		if planDesired(record) {
+			managed = append(managed, record)
+		}
+	}
+	// Only the records that will be in the zone get a companion record.
+	if len(options.OwnerID) > 0 {
+		companions := ownerRecords(managed, options.OwnerID)
+		for _, companion := range companions {
+			planDesired(companion)
+		}
+		records = append(records, companions...)
+	}
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+	plan.planSOA(zone, existingRecords, ignored, options)
+
+	desiredByKey := recordsByKey(records)
+	for _, record := range existingRecords {
+		if _, found := desiredByKey[recordKey(record)]; found {
+			continue
+		}
+		if reason := ignoreReason(ignored, record); len(reason) > 0 {
+			plan.skip(record, "%s", reason)
+			continue
+		}
+		// The SOA record is only ever updated, by planSOA.
+		if record.Type() == "SOA" {
+			continue
+		}
+		// The zone can't be served without its apex NS record.
+		if isApexNS(zone, record) {
+			plan.skip(record, "zone nameservers")
+			continue
+		}
+		// Without an owner ID, records owned by anyone are left alone.
+		if owner := owners.ownerOf(record); owner != options.OwnerID {
+			if len(owner) > 0 {
+				plan.skip(record, "owned by '%s'", owner)
+			} else {
+				plan.skip(record, "not owned by '%s'", options.OwnerID)
+			}
+			continue
+		}
+		if !options.Policy.allowsDelete() {
+			plan.skip(record, "policy %s doesn't allow deletes", options.Policy)
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Existing: record})
+	}
+	plan.Changes = orderChanges(plan.Changes)
+	return plan, nil
+}
+
+// Apply makes the changes in the plan stage by stage, with up to
+// options.Concurrency changes of a stage at a time. It stops at the first
+// error unless options.ContinueOnError is set, and returns the outcome of
+// every change it attempted, in plan order. While continuing, changes that
+// depend on one that failed or wasn't made aren't made either, and are
+// returned with NotApplied set. If changes failed while continuing it
+// returns a *ChangesFailedError listing them.
+func (p *Plan) Apply(service Service, options Options) ([]ChangeResult, error) {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	results := []ChangeResult{}
+	failed := []ChangeResult{}
+	notApplied := []ChangeResult{}
+	// unmade holds the changes that failed or weren't made, which later
+	// changes may depend on.
+	unmade := []Change{}
+	for start := 0; start < len(p.Changes); {
+		end := start + 1
+		for end < len(p.Changes) && p.Changes[end].Stage == p.Changes[start].Stage {
+			end++
+		}
+		changes := []Change{}
+		blocked := map[int]bool{}
+		for ix, change := range p.Changes[start:end] {
+			for _, other := range unmade {
+				if dependsOn(change, other) {
+					blocked[ix] = true
+					break
+				}
+			}
+			if !blocked[ix] {
+				changes = append(changes, change)
+			}
+		}
+		applied := p.applyStage(service, changes, concurrency, options.ContinueOnError)
+		stage := []ChangeResult{}
+		for ix, change := range p.Changes[start:end] {
+			if blocked[ix] {
+				stage = append(stage, ChangeResult{Change: change, NotApplied: true})
+				continue
+			}
+			if len(applied) == 0 {
+				break
+			}
+			stage = append(stage, applied[0])
+			applied = applied[1:]
+		}
+		results = append(results, stage...)
+		for _, result := range stage {
+			if result.NotApplied {
+				glog.Errorf("Change %v depends on a change that wasn't made, not making it.", result.Change)
+				notApplied = append(notApplied, result)
+				unmade = append(unmade, result.Change)
+				continue
+			}
+			if result.Err == nil {
+				continue
+			}
+			if !options.ContinueOnError {
+				return results, result.Err
+			}
+			glog.Errorf("Change %v failed, continuing: %v", result.Change, result.Err)
+			failed = append(failed, result)
+			unmade = append(unmade, result.Change)
+		}
+		start = end
+	}
+	if len(failed) > 0 {
+		return results, &ChangesFailedError{Failed: failed, NotApplied: notApplied}
+	}
+	return results, nil
+}
+
+// applyStage makes changes that don't depend on each other, with up to
+// concurrency at a time, starting them in order. Unless continueOnError is
+// set, no more changes are started once one fails. It returns the outcome of
+// the changes that were started, which are always the first ones.
+func (p *Plan) applyStage(service Service, changes []Change, concurrency int, continueOnError bool) []ChangeResult {
+	results := make([]ChangeResult, len(changes))
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	next := 0
+	failed := false
+	for worker := 0; worker < concurrency && worker < len(changes); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				lock.Lock()
+				if next >= len(changes) || (failed && !continueOnError) {
+					lock.Unlock()
+					return
+				}
+				ix := next
+				next++
+				lock.Unlock()
+
+				glog.V(2).Infof("Applying change: %v", changes[ix])
+				start := time.Now()
+				err := p.applyChange(service, changes[ix])
+				lock.Lock()
+				results[ix] = ChangeResult{Change: changes[ix], Duration: time.Since(start), Err: err}
+				failed = failed || err != nil
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return results[:next]
+}
+
+func (p *Plan) applyChange(service Service, change Change) error {
+	switch change.Action {
+	case ActionCreate:
+		return service.WriteRecord(p.Zone, nil, change.Desired)
+	case ActionUpdate:
+		return service.WriteRecord(p.Zone, change.Existing, change.Desired)
+	case ActionDelete:
+		return service.DeleteRecord(p.Zone, change.Existing)
+	}
+	return fmt.Errorf("Unknown change action: %s", change.Action)
+}
@@ -0,0 +1,162 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrentService tracks how many changes are in flight, and checks that a
+// CNAME is only written once the record it points at exists.
+type concurrentService struct {
+	*FakeDNSService
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+	problems    []string
+}
+
+func (c *concurrentService) begin() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+}
+
+func (c *concurrentService) end() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inFlight--
+}
+
+func (c *concurrentService) WriteRecord(zone Zone, oldRecord, record Record) error {
+	c.begin()
+	defer c.end()
+	if cname, ok := record.(CNameRecord); ok {
+		records, err := c.FakeDNSService.Records(zone)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, existing := range records {
+			found = found || canonicalName(existing.RecordName()) == canonicalName(cname.CanonicalName)
+		}
+		if !found {
+			c.lock.Lock()
+			c.problems = append(c.problems, fmt.Sprintf("%s written before %s", cname.Name, cname.CanonicalName))
+			c.lock.Unlock()
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return c.FakeDNSService.WriteRecord(zone, oldRecord, record)
+}
+
+func (c *concurrentService) DeleteRecord(zone Zone, record Record) error {
+	c.begin()
+	defer c.end()
+	time.Sleep(time.Millisecond)
+	return c.FakeDNSService.DeleteRecord(zone, record)
+}
+
+// concurrentRecords returns A records, CNAMEs pointing at them, and a CNAME
+// pointing at one of those CNAMEs.
+func concurrentRecords() []Record {
+	records := []Record{}
+	for ix := 0; ix < 20; ix++ {
+		records = append(records, AddressRecord{
+			BaseRecord: BaseRecord{Name: fmt.Sprintf("a%d.example.com.", ix), TTL: 25},
+			Addresses:  []string{fmt.Sprintf("10.0.0.%d", ix)},
+		})
+	}
+	for ix := 0; ix < 10; ix++ {
+		records = append(records, CNameRecord{
+			BaseRecord:    BaseRecord{Name: fmt.Sprintf("c%d.example.com.", ix), TTL: 25},
+			CanonicalName: fmt.Sprintf("a%d.example.com.", ix),
+		})
+	}
+	return append(records, CNameRecord{
+		BaseRecord:    BaseRecord{Name: "d.example.com.", TTL: 25},
+		CanonicalName: "c0.example.com.",
+	})
+}
+
+func TestApplyConcurrently(t *testing.T) {
+	fake := &FakeDNSService{}
+	zone := Zone{Name: "test", DNSName: "example.com."}
+	if err := fake.WriteZone(zone, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for ix := 0; ix < 10; ix++ {
+		txt := TXTRecord{BaseRecord: BaseRecord{Name: fmt.Sprintf("old%d.example.com.", ix), TTL: 25}, Text: []string{"old"}}
+		fake.RecordMap[zone.Name][recordKey(txt)] = txt
+	}
+	svc := &concurrentService{FakeDNSService: fake}
+	records := concurrentRecords()
+
+	result, err := Sync(svc, zone, records, Options{Concurrency: 4, Force: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svc.maxInFlight < 2 || svc.maxInFlight > 4 {
+		t.Errorf("expected up to 4 changes at a time, saw %d", svc.maxInFlight)
+	}
+	if len(svc.problems) > 0 {
+		t.Errorf("changes were made out of order: %s", strings.Join(svc.problems, ", "))
+	}
+	if len(result.Applied) != len(result.Changes) {
+		t.Errorf("expected every change to be applied, saw %d of %d", len(result.Applied), len(result.Changes))
+	}
+	for ix := range result.Applied {
+		if result.Applied[ix].Change.String() != result.Changes[ix].String() {
+			t.Errorf("[%d] expected results in plan order, saw %v vs %v", ix, result.Applied[ix].Change, result.Changes[ix])
+		}
+	}
+	recordsOut, err := fake.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(records, recordsOut, t)
+}
+
+func TestApplyConcurrentlyStopsOnError(t *testing.T) {
+	fake := &FakeDNSService{}
+	svc := &failingService{FakeDNSService: fake, name: "a3.example.com."}
+	zone := Zone{Name: "test", DNSName: "example.com."}
+	records := concurrentRecords()
+
+	result, err := Sync(svc, zone, records, Options{Concurrency: 4})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if len(result.Failed()) != 1 {
+		t.Errorf("expected one failed change, saw: %v", result.Failed())
+	}
+	for _, applied := range result.Applied {
+		if applied.Change.Stage != result.Changes[0].Stage {
+			t.Errorf("expected no change after the failed stage, saw: %v", applied.Change)
+		}
+	}
+	report := resultReportOf(t, result)
+	if len(report.Created)+len(report.Failed)+len(report.NotApplied) != len(records) {
+		t.Errorf("expected every change to be reported, saw: %+v", report)
+	}
+
+	fake = &FakeDNSService{}
+	svc = &failingService{FakeDNSService: fake, name: "a3.example.com."}
+	_, err = Sync(svc, zone, records, Options{Concurrency: 4, ContinueOnError: true})
+	if failed, ok := err.(*ChangesFailedError); !ok || len(failed.Failed) != 1 || len(failed.NotApplied) != 1 {
+		t.Fatalf("expected one failed change and one not applied, saw: %v", err)
+	}
+	recordsOut, err := fake.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// c3 points at a3, so it isn't created either.
+	if len(recordsOut) != len(records)-2 {
+		t.Errorf("expected every record but a3 and c3, saw: %v", recordsOut)
+	}
+}
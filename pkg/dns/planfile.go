@@ -0,0 +1,215 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// planVersion is the version of the saved plan format.
+const planVersion = 1
+
+type planJSON struct {
+	Version    int               `json:"version"`
+	Zone       Zone              `json:"zone"`
+	CreateZone bool              `json:"createZone,omitempty"`
+	Existing   []json.RawMessage `json:"existing"`
+	Changes    []changeJSON      `json:"changes"`
+	Skipped    []skipJSON        `json:"skipped"`
+}
+
+type changeJSON struct {
+	Action   ChangeAction    `json:"action"`
+	Stage    int             `json:"stage"`
+	Existing json.RawMessage `json:"existing,omitempty"`
+	Desired  json.RawMessage `json:"desired,omitempty"`
+}
+
+type skipJSON struct {
+	Record json.RawMessage `json:"record"`
+	Reason string          `json:"reason"`
+}
+
+// MarshalJSON saves the plan, along with the records it was computed
+// against, so that it can be reviewed and applied later with ApplyPlan.
+func (p *Plan) MarshalJSON() ([]byte, error) {
+	result := planJSON{
+		Version:    planVersion,
+		Zone:       p.Zone,
+		CreateZone: p.CreateZone,
+		Existing:   []json.RawMessage{},
+		Changes:    []changeJSON{},
+		Skipped:    []skipJSON{},
+	}
+	for _, record := range p.Existing {
+		data, err := encodeRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		result.Existing = append(result.Existing, data)
+	}
+	for _, change := range p.Changes {
+		data := changeJSON{Action: change.Action, Stage: change.Stage}
+		var err error
+		if data.Existing, err = encodeRecord(change.Existing); err != nil {
+			return nil, err
+		}
+		if data.Desired, err = encodeRecord(change.Desired); err != nil {
+			return nil, err
+		}
+		result.Changes = append(result.Changes, data)
+	}
+	for _, skip := range p.Skipped {
+		record, err := encodeRecord(skip.Record)
+		if err != nil {
+			return nil, err
+		}
+		result.Skipped = append(result.Skipped, skipJSON{Record: record, Reason: skip.Reason})
+	}
+	return json.Marshal(result)
+}
+
+func (p *Plan) UnmarshalJSON(b []byte) error {
+	data := planJSON{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	if data.Version != planVersion {
+		return fmt.Errorf("unsupported plan version %d, expected %d", data.Version, planVersion)
+	}
+	plan := Plan{Zone: data.Zone, CreateZone: data.CreateZone}
+	for ix, msg := range data.Existing {
+		record, err := decodeSavedRecord(msg, []interface{}{"existing", ix})
+		if err != nil {
+			return err
+		}
+		plan.Existing = append(plan.Existing, record)
+	}
+	for ix, changeData := range data.Changes {
+		change := Change{Action: changeData.Action, Stage: changeData.Stage}
+		var err error
+		if change.Existing, err = decodeSavedRecord(changeData.Existing, []interface{}{"changes", ix, "existing"}); err != nil {
+			return err
+		}
+		if change.Desired, err = decodeSavedRecord(changeData.Desired, []interface{}{"changes", ix, "desired"}); err != nil {
+			return err
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	for ix, skipData := range data.Skipped {
+		record, err := decodeSavedRecord(skipData.Record, []interface{}{"skipped", ix, "record"})
+		if err != nil {
+			return err
+		}
+		plan.Skipped = append(plan.Skipped, Skip{Record: record, Reason: skipData.Reason})
+	}
+	*p = plan
+	return nil
+}
+
+// encodeRecord marshals a record with its kind, or returns nil for a nil
+// record.
+func encodeRecord(record Record) (json.RawMessage, error) {
+	if record == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields["kind"], err = json.Marshal(record.Type()); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// decodeSavedRecord decodes a record written by encodeRecord. Unlike config
+// records, these can be SOA records read from the provider.
+func decodeSavedRecord(msg json.RawMessage, path []interface{}) (Record, error) {
+	if len(msg) == 0 || string(msg) == "null" {
+		return nil, nil
+	}
+	if hasKind(msg, "SOA") {
+		soa := SOARecord{}
+		if err := decodeStrict(msg, &soa, path); err != nil {
+			return nil, err
+		}
+		return soa, nil
+	}
+	return decodeRecord(msg, path)
+}
+
+func hasKind(msg json.RawMessage, kind string) bool {
+	obj := struct {
+		Kind string `json:"kind"`
+	}{}
+	return json.Unmarshal(msg, &obj) == nil && canonicalType(obj.Kind) == kind
+}
+
+// StalePlanError is returned when the live records no longer match the
+// records a saved plan was computed against.
+type StalePlanError struct {
+	Differences []string
+}
+
+func (s *StalePlanError) Error() string {
+	return fmt.Sprintf("the zone changed since the plan was made, make a new plan:\n  %s", strings.Join(s.Differences, "\n  "))
+}
+
+// ApplyPlan applies a saved plan, after checking that the live records still
+// match the records the plan was computed against. It returns a
+// *StalePlanError if they don't, without making any change. Plans made for a
+// zone that didn't exist are refused, since providers create records, such as
+// the SOA and apex NS records, along with the zone, which the plan doesn't
+// know about. Only the ContinueOnError and Concurrency options are used.
+func ApplyPlan(service Service, plan *Plan, options Options) (*Result, error) {
+	started := time.Now()
+	if plan.CreateZone {
+		return nil, fmt.Errorf("the plan was made for zone %s before it existed, create the zone with sync and make a new plan", plan.Zone.Name)
+	}
+	zone, err := findZone(service, plan.Zone)
+	if err != nil {
+		return nil, err
+	}
+	if zone == nil {
+		return nil, &StalePlanError{Differences: []string{fmt.Sprintf("zone %s doesn't exist", plan.Zone.Name)}}
+	}
+	records, err := service.Records(plan.Zone)
+	if err != nil {
+		return nil, err
+	}
+	if differences := recordDifferences(plan.Existing, records); len(differences) > 0 {
+		return nil, &StalePlanError{Differences: differences}
+	}
+	result := &Result{Plan: plan, Started: started}
+	result.Applied, err = plan.Apply(service, options)
+	result.Duration = time.Since(started)
+	return result, err
+}
+
+// recordDifferences describes how the records in after differ from before.
+func recordDifferences(before, after []Record) []string {
+	differences := []string{}
+	beforeByKey, afterByKey := recordsByKey(before), recordsByKey(after)
+	for _, record := range before {
+		current, found := afterByKey[recordKey(record)]
+		if !found {
+			differences = append(differences, fmt.Sprintf("%s %s was deleted", record.Type(), record.RecordName()))
+		} else if recordIsDifferent(record, current) {
+			differences = append(differences, fmt.Sprintf("%s %s was changed", record.Type(), record.RecordName()))
+		}
+	}
+	for _, record := range after {
+		if _, found := beforeByKey[recordKey(record)]; !found {
+			differences = append(differences, fmt.Sprintf("%s %s was created", record.Type(), record.RecordName()))
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
@@ -0,0 +1,233 @@
+package dns
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func planFileRecords() []Record {
+	return []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+		CNameRecord{
+			BaseRecord: BaseRecord{
+				Name: "cname.example.com.",
+				TTL:  25,
+			},
+			CanonicalName: "www.example.com.",
+		},
+	}
+}
+
+// savePlan round trips a plan through JSON, as if it was written to a file.
+func savePlan(t *testing.T, plan *Plan) *Plan {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	saved := &Plan{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return saved
+}
+
+func TestSavedPlan(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+		SOA:     &SOA{NegativeTTL: 60},
+	}
+	records := planFileRecords()
+
+	plan, err := PlanSync(svc, zone, records, Options{OwnerID: "team"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	saved := savePlan(t, plan)
+	if saved.String() != plan.String() || !saved.CreateZone {
+		t.Errorf("expected the same plan, saw:\n%v\nvs\n%v", saved, plan)
+	}
+	// A plan for a zone that doesn't exist can't be applied.
+	if _, err := ApplyPlan(svc, saved, Options{}); err == nil {
+		t.Errorf("expected an error")
+	}
+	if len(svc.ZoneMap) != 0 {
+		t.Errorf("expected no changes, saw: %v", svc.ZoneMap)
+	}
+	if _, err := Sync(svc, zone, records, Options{OwnerID: "team"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(append(append([]Record{}, records...), ownerRecords(records, "team")...), recordsOut, t)
+
+	soa := SOARecord{
+		BaseRecord: BaseRecord{Name: "example.com.", TTL: 300},
+		Nameserver: "ns1.provider.net.",
+		Email:      "hostmaster.provider.net.",
+		Serial:     1,
+		Minimum:    3600,
+	}
+	svc.RecordMap[zone.Name][recordKey(soa)] = soa
+	records[0] = AddressRecord{
+		BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25},
+		Addresses:  []string{"5.6.7.8"},
+	}
+	plan, err = PlanSync(svc, zone, records, Options{OwnerID: "team"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 2 {
+		t.Errorf("expected the A and SOA records to be updated, saw: %v", plan)
+	}
+	saved = savePlan(t, plan)
+	if saved.String() != plan.String() || len(saved.Existing) != len(plan.Existing) {
+		t.Errorf("expected the same plan, saw:\n%v\nvs\n%v", saved, plan)
+	}
+	if _, err := ApplyPlan(svc, saved, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svc.RecordMap[zone.Name][recordKey(soa)].(SOARecord).Minimum != 60 {
+		t.Errorf("expected the SOA record to be updated")
+	}
+	if svc.RecordMap[zone.Name][recordKey(records[0])].RRData()[0] != "5.6.7.8" {
+		t.Errorf("expected the A record to be updated")
+	}
+}
+
+// seedingService creates the SOA and apex NS records along with a zone, as
+// real providers do.
+type seedingService struct {
+	*FakeDNSService
+}
+
+func (s *seedingService) WriteZone(zone Zone, create bool) error {
+	zone.Nameservers = []string{"ns1.provider.net."}
+	if err := s.FakeDNSService.WriteZone(zone, create); err != nil || !create {
+		return err
+	}
+	soa := SOARecord{
+		BaseRecord: BaseRecord{Name: zone.DNSName, TTL: 300},
+		Nameserver: "ns1.provider.net.",
+		Email:      "hostmaster.provider.net.",
+		Serial:     1,
+		Minimum:    3600,
+	}
+	ns := NSRecord{BaseRecord: BaseRecord{Name: zone.DNSName, TTL: 300}, Nameservers: zone.Nameservers}
+	for _, record := range []Record{soa, ns} {
+		if err := s.FakeDNSService.WriteRecord(zone, nil, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestApplyPlanForNewZone(t *testing.T) {
+	svc := &seedingService{FakeDNSService: &FakeDNSService{}}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+		SOA:     &SOA{NegativeTTL: 60},
+	}
+	records := []Record{
+		NSRecord{BaseRecord: BaseRecord{Name: "example.com.", TTL: 300}, Nameservers: []string{"ns1.example.net."}},
+		AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4"}},
+	}
+	plan, err := PlanSync(svc, zone, records, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ApplyPlan(svc, savePlan(t, plan), Options{}); err == nil {
+		t.Errorf("expected an error")
+	}
+	if len(svc.ZoneMap) != 0 {
+		t.Errorf("expected no zone to be created, saw: %v", svc.ZoneMap)
+	}
+
+	// Once the zone exists, plans account for the records the provider
+	// created with it.
+	if err := svc.WriteZone(zone, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan, err = PlanSync(svc, zone, records, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ApplyPlan(svc, savePlan(t, plan), Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(recordsOut) != 3 {
+		t.Errorf("expected the SOA, NS and A records, saw: %v", recordsOut)
+	}
+	for _, record := range recordsOut {
+		switch record := record.(type) {
+		case SOARecord:
+			if record.Minimum != 60 {
+				t.Errorf("expected the SOA record to be updated, saw: %v", record)
+			}
+		case NSRecord:
+			if len(record.Nameservers) != 2 {
+				t.Errorf("expected both nameservers, saw: %v", record)
+			}
+		}
+	}
+}
+
+func TestApplyStalePlan(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := planFileRecords()
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan, err := PlanSync(svc, zone, records[:1], Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	saved := savePlan(t, plan)
+
+	// Someone edits the zone between the plan and the apply.
+	edited := records[0].(AddressRecord)
+	edited.Addresses = []string{"5.6.7.8"}
+	svc.RecordMap[zone.Name][recordKey(edited)] = edited
+	extra := TXTRecord{BaseRecord: BaseRecord{Name: "txt.example.com.", TTL: 25}, Text: []string{"hello"}}
+	svc.RecordMap[zone.Name][recordKey(extra)] = extra
+
+	_, err = ApplyPlan(svc, saved, Options{})
+	stale, ok := err.(*StalePlanError)
+	if !ok || len(stale.Differences) != 2 {
+		t.Fatalf("expected a stale plan error, saw: %v", err)
+	}
+	if _, found := svc.RecordMap[zone.Name][recordKey(records[1])]; !found {
+		t.Errorf("expected no changes to be made")
+	}
+}
+
+func TestLoadPlanErrors(t *testing.T) {
+	tests := []string{
+		`{"version": 2, "zone": {"name": "test"}}`,
+		`{"version": 1, "zone": {"name": "test"}, "changes": [{"action": "create", "desired": {"kind": "MX", "name": "a."}}]}`,
+		`{"version": 1, "zone": {"name": "test"}, "existing": [{"kind": "A", "name": "a.", "adresses": []}]}`,
+	}
+	for ix, test := range tests {
+		if err := json.Unmarshal([]byte(test), &Plan{}); err == nil {
+			t.Errorf("[%d] expected an error", ix)
+		}
+	}
+}
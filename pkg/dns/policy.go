@@ -0,0 +1,38 @@
+package dns
+
+import (
+	"fmt"
+)
+
+// Policy controls which kinds of record changes Sync is allowed to make.
+type Policy string
+
+const (
+	// PolicySync creates, updates and deletes records so the zone matches
+	// the config exactly.
+	PolicySync Policy = "sync"
+	// PolicyUpsertOnly creates and updates records but never deletes them.
+	PolicyUpsertOnly Policy = "upsert-only"
+	// PolicyCreateOnly only creates records that don't exist yet.
+	PolicyCreateOnly Policy = "create-only"
+)
+
+// ParsePolicy converts a policy name into a Policy. The empty string maps to
+// PolicySync.
+func ParsePolicy(name string) (Policy, error) {
+	switch Policy(name) {
+	case "", PolicySync:
+		return PolicySync, nil
+	case PolicyUpsertOnly, PolicyCreateOnly:
+		return Policy(name), nil
+	}
+	return "", fmt.Errorf("Unknown sync policy: %s", name)
+}
+
+func (p Policy) allowsUpdate() bool {
+	return p != PolicyCreateOnly
+}
+
+func (p Policy) allowsDelete() bool {
+	return p == "" || p == PolicySync
+}
@@ -0,0 +1,173 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChangeResult is the outcome of applying a single change.
+type ChangeResult struct {
+	Change
+	Duration time.Duration
+	Err      error
+	// NotApplied is true if the change wasn't attempted because it depends
+	// on a change that failed.
+	NotApplied bool
+}
+
+// Result describes a sync: the plan, and the outcome of every change that was
+// attempted. Unless the sync continued on errors, changes after a failed one
+// aren't attempted. If it did, changes that depend on a failed one aren't
+// either.
+type Result struct {
+	*Plan
+	Started  time.Time
+	Duration time.Duration
+	Applied  []ChangeResult
+}
+
+// Failed returns the changes that failed.
+func (r *Result) Failed() []ChangeResult {
+	failed := []ChangeResult{}
+	for _, applied := range r.Applied {
+		if applied.Err != nil {
+			failed = append(failed, applied)
+		}
+	}
+	return failed
+}
+
+// String returns the plan, followed by any changes that failed or weren't
+// made because they depend on one that failed.
+func (r *Result) String() string {
+	lines := []string{r.Plan.String()}
+	for _, applied := range r.Applied {
+		if applied.NotApplied {
+			lines = append(lines, fmt.Sprintf("not applied %s: depends on a failed change", applied.Change))
+		}
+	}
+	for _, failed := range r.Failed() {
+		lines = append(lines, fmt.Sprintf("failed %s: %v", failed.Change, failed.Err))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ChangesFailedError is returned when changes failed but the others were
+// still applied, except for those that depend on a failed change.
+type ChangesFailedError struct {
+	Failed     []ChangeResult
+	NotApplied []ChangeResult
+}
+
+func (c *ChangesFailedError) Error() string {
+	lines := []string{}
+	for _, failed := range c.Failed {
+		lines = append(lines, fmt.Sprintf("%s: %v", failed.Change, failed.Err))
+	}
+	message := fmt.Sprintf("%d changes failed:\n  %s", len(c.Failed), strings.Join(lines, "\n  "))
+	if len(c.NotApplied) > 0 {
+		lines = []string{}
+		for _, notApplied := range c.NotApplied {
+			lines = append(lines, notApplied.Change.String())
+		}
+		message += fmt.Sprintf("\n%d changes that depend on them weren't made:\n  %s", len(c.NotApplied), strings.Join(lines, "\n  "))
+	}
+	return message
+}
+
+type recordValue struct {
+	TTL  int64    `json:"ttl"`
+	Data []string `json:"data"`
+}
+
+func valueOf(record Record) *recordValue {
+	if record == nil {
+		return nil
+	}
+	return &recordValue{TTL: record.TimeToLive(), Data: record.RRData()}
+}
+
+type changeReport struct {
+	Action   ChangeAction `json:"action,omitempty"`
+	Kind     string       `json:"kind"`
+	Name     string       `json:"name"`
+	Stage    int          `json:"stage,omitempty"`
+	Before   *recordValue `json:"before,omitempty"`
+	After    *recordValue `json:"after,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+	Duration string       `json:"duration,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type resultReport struct {
+	Zone     string         `json:"zone"`
+	DNSName  string         `json:"dnsName"`
+	Started  time.Time      `json:"started"`
+	Duration string         `json:"duration"`
+	Created  []changeReport `json:"created"`
+	Updated  []changeReport `json:"updated"`
+	Deleted  []changeReport `json:"deleted"`
+	Skipped  []changeReport `json:"skipped"`
+	Failed   []changeReport `json:"failed"`
+	// NotApplied lists the changes that weren't attempted because an
+	// earlier change, or one they depend on, failed.
+	NotApplied []changeReport `json:"notApplied"`
+}
+
+// MarshalJSON reports every created, updated, deleted, skipped and failed
+// record set, with its values before and after.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	report := resultReport{
+		Zone:       r.Zone.Name,
+		DNSName:    r.Zone.DNSName,
+		Started:    r.Started,
+		Duration:   r.Duration.String(),
+		Created:    []changeReport{},
+		Updated:    []changeReport{},
+		Deleted:    []changeReport{},
+		Skipped:    []changeReport{},
+		Failed:     []changeReport{},
+		NotApplied: []changeReport{},
+	}
+	for ix, change := range r.Changes {
+		record := change.record()
+		entry := changeReport{
+			Kind:   record.Type(),
+			Name:   record.RecordName(),
+			Stage:  change.Stage,
+			Before: valueOf(change.Existing),
+			After:  valueOf(change.Desired),
+		}
+		if ix >= len(r.Applied) || r.Applied[ix].NotApplied {
+			entry.Action = change.Action
+			report.NotApplied = append(report.NotApplied, entry)
+			continue
+		}
+		entry.Duration = r.Applied[ix].Duration.String()
+		if err := r.Applied[ix].Err; err != nil {
+			entry.Action = change.Action
+			entry.Error = err.Error()
+			report.Failed = append(report.Failed, entry)
+			continue
+		}
+		switch change.Action {
+		case ActionCreate:
+			report.Created = append(report.Created, entry)
+		case ActionUpdate:
+			report.Updated = append(report.Updated, entry)
+		case ActionDelete:
+			report.Deleted = append(report.Deleted, entry)
+		}
+	}
+	for _, skip := range r.Skipped {
+		report.Skipped = append(report.Skipped, changeReport{
+			Kind:   skip.Record.Type(),
+			Name:   skip.Record.RecordName(),
+			Before: valueOf(skip.Record),
+			Reason: skip.Reason,
+		})
+	}
+	return json.Marshal(report)
+}
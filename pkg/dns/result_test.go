@@ -0,0 +1,216 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// failingService fails writes to one record name.
+type failingService struct {
+	*FakeDNSService
+	name string
+}
+
+func (f *failingService) WriteRecord(zone Zone, oldRecord, record Record) error {
+	if record.RecordName() == f.name {
+		return fmt.Errorf("write failed")
+	}
+	return f.FakeDNSService.WriteRecord(zone, oldRecord, record)
+}
+
+func (f *failingService) DeleteRecord(zone Zone, record Record) error {
+	if record.RecordName() == f.name {
+		return fmt.Errorf("delete failed")
+	}
+	return f.FakeDNSService.DeleteRecord(zone, record)
+}
+
+func resultReportOf(t *testing.T, result *Result) resultReport {
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := resultReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return report
+}
+
+func TestResultJSON(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := planFileRecords()
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated := records[0].(AddressRecord)
+	updated.Addresses = []string{"5.6.7.8"}
+	txt := TXTRecord{BaseRecord: BaseRecord{Name: "txt.example.com.", TTL: 25}, Text: []string{"hello"}}
+	result, err := Sync(svc, zone, []Record{updated, txt}, Options{Force: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := resultReportOf(t, result)
+	if report.Zone != "test" || report.DNSName != "example.com." {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if len(report.Created) != 1 || report.Created[0].Name != "txt.example.com." || report.Created[0].Before != nil {
+		t.Errorf("expected the TXT record to be created, saw: %+v", report.Created)
+	}
+	if len(report.Updated) != 1 || report.Updated[0].Before.Data[0] != "1.2.3.4" || report.Updated[0].After.Data[0] != "5.6.7.8" {
+		t.Errorf("expected the A record to be updated, saw: %+v", report.Updated)
+	}
+	if len(report.Deleted) != 1 || report.Deleted[0].Kind != "CNAME" || report.Deleted[0].After != nil {
+		t.Errorf("expected the CNAME record to be deleted, saw: %+v", report.Deleted)
+	}
+	if len(report.Failed) != 0 || len(report.NotApplied) != 0 {
+		t.Errorf("expected nothing to fail, saw: %+v", report)
+	}
+}
+
+func TestResultJSONFailure(t *testing.T) {
+	fake := &FakeDNSService{}
+	svc := &failingService{FakeDNSService: fake, name: "cname.example.com."}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := planFileRecords()
+	txt := TXTRecord{BaseRecord: BaseRecord{Name: "txt.example.com.", TTL: 25}, Text: []string{"hello"}}
+	records = append(records, txt)
+
+	result, err := Sync(svc, zone, records, Options{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if result == nil || len(result.Failed()) != 1 {
+		t.Fatalf("expected one failed change, saw: %v", result)
+	}
+	report := resultReportOf(t, result)
+	if len(report.Failed) != 1 || report.Failed[0].Name != "cname.example.com." || report.Failed[0].Error != "write failed" {
+		t.Errorf("expected the CNAME record to fail, saw: %+v", report.Failed)
+	}
+	if len(report.Created)+len(report.NotApplied) != 2 {
+		t.Errorf("expected the other records to be created or not applied, saw: %+v", report)
+	}
+	for _, entry := range report.NotApplied {
+		if entry.Action != ActionCreate {
+			t.Errorf("expected a create action, saw: %+v", entry)
+		}
+	}
+}
+
+func TestSyncContinueOnError(t *testing.T) {
+	fake := &FakeDNSService{}
+	svc := &failingService{FakeDNSService: fake, name: "cname.example.com."}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := planFileRecords()
+	txt := TXTRecord{BaseRecord: BaseRecord{Name: "txt.example.com.", TTL: 25}, Text: []string{"hello"}}
+	records = append(records, txt)
+
+	result, err := Sync(svc, zone, records, Options{ContinueOnError: true})
+	failed, ok := err.(*ChangesFailedError)
+	if !ok || len(failed.Failed) != 1 || failed.Failed[0].Change.Desired.RecordName() != "cname.example.com." {
+		t.Fatalf("expected the CNAME record to fail, saw: %v", err)
+	}
+	recordsOut, err := fake.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual([]Record{records[0], txt}, recordsOut, t)
+
+	report := resultReportOf(t, result)
+	if len(report.Created) != 2 || len(report.Failed) != 1 || len(report.NotApplied) != 0 {
+		t.Errorf("expected 2 created and 1 failed, saw: %+v", report)
+	}
+}
+
+func TestSyncContinueOnErrorSkipsDependents(t *testing.T) {
+	fake := &FakeDNSService{}
+	svc := &failingService{FakeDNSService: fake, name: "www.example.com."}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	if err := fake.WriteZone(zone, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	existing := AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4"}}
+	if err := fake.WriteRecord(zone, nil, existing); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ok := AddressRecord{BaseRecord: BaseRecord{Name: "ok.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4"}}
+	records := []Record{
+		// Replacing the A record needs it deleted first, which fails.
+		CNameRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, CanonicalName: "web.example.net."},
+		CNameRecord{BaseRecord: BaseRecord{Name: "alias.example.com.", TTL: 25}, CanonicalName: "www.example.com."},
+		ok,
+	}
+
+	result, err := Sync(svc, zone, records, Options{ContinueOnError: true})
+	failed, isFailed := err.(*ChangesFailedError)
+	if !isFailed || len(failed.Failed) != 1 || len(failed.NotApplied) != 2 {
+		t.Fatalf("expected one failed change and two not applied, saw: %v", err)
+	}
+	recordsOut, err := fake.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual([]Record{existing, ok}, recordsOut, t)
+
+	report := resultReportOf(t, result)
+	if len(report.Created) != 1 || len(report.Failed) != 1 || report.Failed[0].Action != ActionDelete {
+		t.Errorf("expected 1 created and the delete to fail, saw: %+v", report)
+	}
+	if len(report.NotApplied) != 2 || report.NotApplied[0].Name != "www.example.com." || report.NotApplied[1].Name != "alias.example.com." {
+		t.Errorf("expected both CNAMEs not to be applied, saw: %+v", report.NotApplied)
+	}
+}
+
+func TestSyncContinueOnErrorKeepsOwnerRecords(t *testing.T) {
+	www := AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4"}}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	options := Options{OwnerID: "team", ContinueOnError: true}
+
+	// A record isn't created if its ownership record can't be.
+	fake := &FakeDNSService{}
+	svc := &failingService{FakeDNSService: fake, name: "_dns-sync.www.example.com."}
+	if _, err := Sync(svc, zone, []Record{www}, options); err == nil {
+		t.Fatalf("expected an error")
+	}
+	recordsOut, err := fake.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual([]Record{}, recordsOut, t)
+
+	// An ownership record isn't deleted if its record can't be.
+	if _, err := Sync(fake, zone, []Record{www}, options); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svc = &failingService{FakeDNSService: fake, name: "www.example.com."}
+	result, err := Sync(svc, zone, []Record{}, options)
+	if failed, ok := err.(*ChangesFailedError); !ok || len(failed.Failed) != 1 || len(failed.NotApplied) != 1 {
+		t.Fatalf("expected one failed change and one not applied, saw: %v", err)
+	}
+	recordsOut, err = fake.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(append([]Record{www}, ownerRecords([]Record{www}, "team")...), recordsOut, t)
+	if report := resultReportOf(t, result); len(report.NotApplied) != 1 || report.NotApplied[0].Name != "_dns-sync.www.example.com." {
+		t.Errorf("expected the ownership record not to be deleted, saw: %+v", report.NotApplied)
+	}
+}
@@ -0,0 +1,221 @@
+package dns
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultRetryJitter    = 0.1
+)
+
+// TransientError is returned by providers for errors that may go away if the
+// call is retried, e.g. throttling or a 5xx response.
+type TransientError struct {
+	Err error
+	// Throttled is true if the provider rejected the call without processing
+	// it, so that it is safe to retry even if it isn't idempotent.
+	Throttled bool
+	// RetryAfter is how long the provider asked to wait before retrying, or 0.
+	RetryAfter time.Duration
+}
+
+func (t *TransientError) Error() string {
+	return t.Err.Error()
+}
+
+// TransientStatus returns a *TransientError wrapping err if code is an HTTP
+// status code worth retrying, or err otherwise.
+func TransientStatus(err error, code int, header http.Header) error {
+	if code != http.StatusTooManyRequests && code < 500 {
+		return err
+	}
+	return &TransientError{
+		Err:        err,
+		Throttled:  code == http.StatusTooManyRequests,
+		RetryAfter: ParseRetryAfter(header.Get("Retry-After")),
+	}
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date. It returns 0 if value isn't valid.
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	if delay := time.Until(date); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// RetryingService wraps a Service, retrying calls that fail with a
+// *TransientError with jittered exponential backoff, and limiting the rate of
+// calls. Reads and zone updates are retried on any transient error; other
+// writes only when the provider throttled them, since they may have been made
+// even though the call failed.
+type RetryingService struct {
+	Service
+	// MaxAttempts is how many times a call is tried, 1 disables retries.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter randomly spreads backoffs by up to this fraction, e.g. 0.1 for
+	// plus or minus 10%. 0 uses the default of 10%, a negative value disables
+	// jitter.
+	Jitter float64
+	// RateLimit is the maximum number of calls per second, or 0 for no limit.
+	RateLimit float64
+
+	lock sync.Mutex
+	// next is when the rate limit allows the next call.
+	next   time.Time
+	sleep  func(time.Duration)
+	random func() float64
+}
+
+var _ = Service(&RetryingService{})
+
+func (r *RetryingService) Zones() ([]Zone, error) {
+	var zones []Zone
+	err := r.call("list zones", true, func() (err error) {
+		zones, err = r.Service.Zones()
+		return err
+	})
+	return zones, err
+}
+
+func (r *RetryingService) WriteZone(zone Zone, create bool) error {
+	return r.call(fmt.Sprintf("write zone %s", zone.Name), !create, func() error {
+		return r.Service.WriteZone(zone, create)
+	})
+}
+
+func (r *RetryingService) DeleteZone(zone Zone) error {
+	return r.call(fmt.Sprintf("delete zone %s", zone.Name), false, func() error {
+		return r.Service.DeleteZone(zone)
+	})
+}
+
+func (r *RetryingService) Records(zone Zone) ([]Record, error) {
+	var records []Record
+	err := r.call(fmt.Sprintf("list records of %s", zone.Name), true, func() (err error) {
+		records, err = r.Service.Records(zone)
+		return err
+	})
+	return records, err
+}
+
+func (r *RetryingService) WriteRecord(zone Zone, oldRecord, record Record) error {
+	return r.call(fmt.Sprintf("write %s %s", record.Type(), record.RecordName()), false, func() error {
+		return r.Service.WriteRecord(zone, oldRecord, record)
+	})
+}
+
+func (r *RetryingService) DeleteRecord(zone Zone, record Record) error {
+	return r.call(fmt.Sprintf("delete %s %s", record.Type(), record.RecordName()), false, func() error {
+		return r.Service.DeleteRecord(zone, record)
+	})
+}
+
+// call calls fn until it succeeds, fails with an error that can't be
+// retried, or runs out of attempts.
+func (r *RetryingService) call(description string, idempotent bool, fn func() error) error {
+	attempts := r.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultMaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		r.wait()
+		err := fn()
+		transient, ok := err.(*TransientError)
+		if !ok || (!idempotent && !transient.Throttled) {
+			return err
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("%s failed after %d attempts: %v", description, attempt, err)
+		}
+		delay := r.backoff(attempt)
+		if transient.RetryAfter > delay {
+			delay = transient.RetryAfter
+		}
+		glog.Warningf("%s failed, retrying in %v: %v", description, delay, err)
+		r.doSleep(delay)
+	}
+}
+
+// backoff returns how long to wait after the given failed attempt.
+func (r *RetryingService) backoff(attempt int) time.Duration {
+	delay := r.InitialBackoff
+	if delay <= 0 {
+		delay = defaultInitialBackoff
+	}
+	max := r.MaxBackoff
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	for ; attempt > 1 && delay < max; attempt-- {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	jitter := r.Jitter
+	if jitter == 0 {
+		jitter = defaultRetryJitter
+	} else if jitter < 0 {
+		jitter = 0
+	}
+	random := r.random
+	if random == nil {
+		random = rand.Float64
+	}
+	return delay + time.Duration(float64(delay)*jitter*(2*random()-1))
+}
+
+// wait blocks until the rate limit allows another call.
+func (r *RetryingService) wait() {
+	if r.RateLimit <= 0 {
+		return
+	}
+	r.lock.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(time.Duration(float64(time.Second) / r.RateLimit))
+	r.lock.Unlock()
+	if delay > 0 {
+		r.doSleep(delay)
+	}
+}
+
+func (r *RetryingService) doSleep(delay time.Duration) {
+	if r.sleep != nil {
+		r.sleep(delay)
+		return
+	}
+	time.Sleep(delay)
+}
@@ -0,0 +1,231 @@
+package dns
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// flakyService fails the first failures calls with err.
+type flakyService struct {
+	*FakeDNSService
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyService) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyService) Records(zone Zone) ([]Record, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.FakeDNSService.Records(zone)
+}
+
+func (f *flakyService) WriteRecord(zone Zone, oldRecord, record Record) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.FakeDNSService.WriteRecord(zone, oldRecord, record)
+}
+
+func newRetryingService(svc Service, sleeps *[]time.Duration) *RetryingService {
+	return &RetryingService{
+		Service:     svc,
+		MaxAttempts: 3,
+		sleep:       func(d time.Duration) { *sleeps = append(*sleeps, d) },
+		random:      func() float64 { return 0.5 },
+	}
+}
+
+func TestRetryingService(t *testing.T) {
+	serverError := &TransientError{Err: fmt.Errorf("503")}
+	throttled := &TransientError{Err: fmt.Errorf("429"), Throttled: true, RetryAfter: 5 * time.Second}
+	record := TXTRecord{BaseRecord: BaseRecord{Name: "txt.example.com.", TTL: 25}, Text: []string{"hello"}}
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		write     bool
+		expectErr bool
+		sleeps    []time.Duration
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:     "read retried",
+			failures: 2,
+			err:      serverError,
+			sleeps:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:      "read out of attempts",
+			failures:  3,
+			err:       serverError,
+			expectErr: true,
+			sleeps:    []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:      "permanent error",
+			failures:  1,
+			err:       fmt.Errorf("bad request"),
+			expectErr: true,
+		},
+		{
+			name:      "write not retried",
+			failures:  1,
+			err:       serverError,
+			write:     true,
+			expectErr: true,
+		},
+		{
+			name:     "throttled write retried",
+			failures: 1,
+			err:      throttled,
+			write:    true,
+			sleeps:   []time.Duration{5 * time.Second},
+		},
+	}
+	for _, test := range tests {
+		fake := &FakeDNSService{}
+		zone := Zone{Name: "test", DNSName: "example.com."}
+		if err := fake.WriteZone(zone, true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		flaky := &flakyService{FakeDNSService: fake, failures: test.failures, err: test.err}
+		sleeps := []time.Duration{}
+		svc := newRetryingService(flaky, &sleeps)
+		var err error
+		if test.write {
+			err = svc.WriteRecord(zone, nil, record)
+		} else {
+			_, err = svc.Records(zone)
+		}
+		if test.expectErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if !test.expectErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if fmt.Sprint(sleeps) != fmt.Sprint(test.sleeps) {
+			t.Errorf("%s: expected sleeps %v, saw %v", test.name, test.sleeps, sleeps)
+		}
+	}
+}
+
+func TestRetryingServiceSync(t *testing.T) {
+	fake := &FakeDNSService{}
+	flaky := &flakyService{FakeDNSService: fake, failures: 1, err: &TransientError{Err: fmt.Errorf("429"), Throttled: true}}
+	sleeps := []time.Duration{}
+	svc := newRetryingService(flaky, &sleeps)
+	zone := Zone{Name: "test", DNSName: "example.com."}
+	records := planFileRecords()
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recordsOut, err := fake.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(records, recordsOut, t)
+	if len(sleeps) != 1 {
+		t.Errorf("expected one retry, saw: %v", sleeps)
+	}
+}
+
+func TestRetryingServiceBackoff(t *testing.T) {
+	svc := &RetryingService{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Jitter:         0.1,
+		random:         func() float64 { return 1 },
+	}
+	expected := []time.Duration{1100 * time.Millisecond, 2200 * time.Millisecond, 4400 * time.Millisecond, 5500 * time.Millisecond, 5500 * time.Millisecond}
+	for ix, delay := range expected {
+		if backoff := svc.backoff(ix + 1); backoff != delay {
+			t.Errorf("[%d] expected %v, saw %v", ix, delay, backoff)
+		}
+	}
+
+	svc.Jitter = 0
+	if backoff := svc.backoff(1); backoff != 1100*time.Millisecond {
+		t.Errorf("expected the default jitter, saw %v", backoff)
+	}
+	svc.Jitter = -1
+	if backoff := svc.backoff(1); backoff != time.Second {
+		t.Errorf("expected no jitter, saw %v", backoff)
+	}
+}
+
+func TestRetryingServiceRateLimit(t *testing.T) {
+	sleeps := []time.Duration{}
+	svc := newRetryingService(&FakeDNSService{}, &sleeps)
+	svc.RateLimit = 10
+	for ix := 0; ix < 3; ix++ {
+		if _, err := svc.Zones(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if len(sleeps) != 2 || sleeps[0] < 50*time.Millisecond || sleeps[1] < 150*time.Millisecond {
+		t.Errorf("expected the calls to be spread 100ms apart, saw: %v", sleeps)
+	}
+}
+
+func TestTransientStatus(t *testing.T) {
+	err := fmt.Errorf("failed")
+	header := http.Header{"Retry-After": []string{"7"}}
+	tests := []struct {
+		code       int
+		transient  bool
+		throttled  bool
+		retryAfter time.Duration
+	}{
+		{code: 400},
+		{code: 404},
+		{code: 429, transient: true, throttled: true, retryAfter: 7 * time.Second},
+		{code: 500, transient: true, retryAfter: 7 * time.Second},
+		{code: 503, transient: true, retryAfter: 7 * time.Second},
+	}
+	for _, test := range tests {
+		result := TransientStatus(err, test.code, header)
+		transient, ok := result.(*TransientError)
+		if ok != test.transient {
+			t.Errorf("[%d] expected transient %v, saw %v", test.code, test.transient, result)
+			continue
+		}
+		if ok && (transient.Throttled != test.throttled || transient.RetryAfter != test.retryAfter) {
+			t.Errorf("[%d] unexpected error: %+v", test.code, transient)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+	for _, test := range tests {
+		if delay := ParseRetryAfter(test.value); delay != test.expected {
+			t.Errorf("%q: expected %v, saw %v", test.value, test.expected, delay)
+		}
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if delay := ParseRetryAfter(date); delay < 50*time.Second || delay > time.Minute {
+		t.Errorf("%q: expected about a minute, saw %v", date, delay)
+	}
+}
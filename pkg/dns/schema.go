@@ -0,0 +1,289 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// recordTypes maps each supported record kind to its Go type.
+var recordTypes = map[string]Record{
+	"A":     AddressRecord{},
+	"CNAME": CNameRecord{},
+	"NS":    NSRecord{},
+	"TXT":   TXTRecord{},
+}
+
+var (
+	recordType = reflect.TypeOf((*Record)(nil)).Elem()
+	ttlType    = reflect.TypeOf(TTL(0))
+)
+
+// schemaRequired lists the required fields of config types other than records.
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(Config{}): {"zone"},
+	reflect.TypeOf(Zone{}):   {"name", "dnsName"},
+}
+
+// Schema returns a JSON Schema for config files, generated from the config
+// types.
+func Schema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(Config{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "dns-sync config"
+	// Variables may be written as any scalar.
+	schema["properties"].(map[string]interface{})["variables"] = map[string]interface{}{
+		"type": "object",
+		"additionalProperties": map[string]interface{}{
+			"type": []interface{}{"string", "number", "boolean"},
+		},
+	}
+	return schema
+}
+
+// SchemaJSON returns the schema, indented for publishing.
+func SchemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func schemaFor(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == ttlType:
+		return map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "integer", "minimum": 0, "maximum": maxTTL},
+				map[string]interface{}{"type": "string", "pattern": "^([0-9]+|([0-9]+[smhdw])+)$"},
+			},
+		}
+	case t == recordType:
+		return recordSchema()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		addProperties(t, properties)
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if required, found := schemaRequired[t]; found {
+			schema["required"] = stringsToInterfaces(required)
+		}
+		return schema
+	}
+	panic(fmt.Sprintf("no schema for %v", t))
+}
+
+// addProperties adds the JSON fields of a struct, including those of embedded
+// structs, to properties.
+func addProperties(t reflect.Type, properties map[string]interface{}) {
+	for ix := 0; ix < t.NumField(); ix++ {
+		field := t.Field(ix)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && len(name) == 0 {
+			addProperties(field.Type, properties)
+			continue
+		}
+		if len(field.PkgPath) > 0 || name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type)
+	}
+}
+
+func recordSchema() map[string]interface{} {
+	kinds := []string{}
+	for kind := range recordTypes {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	branches := []interface{}{}
+	for _, kind := range kinds {
+		schema := schemaFor(reflect.TypeOf(recordTypes[kind]))
+		schema["properties"].(map[string]interface{})["kind"] = map[string]interface{}{
+			"type":    "string",
+			"pattern": caseInsensitivePattern(kind),
+		}
+		schema["required"] = stringsToInterfaces(append([]string{"kind", "name"}, requiredFields[kind]...))
+		branches = append(branches, schema)
+	}
+	return map[string]interface{}{"oneOf": branches}
+}
+
+// caseInsensitivePattern matches value in any case, without relying on regex
+// flags that not every JSON Schema implementation supports.
+func caseInsensitivePattern(value string) string {
+	pattern := "^"
+	for _, c := range value {
+		lower, upper := strings.ToLower(string(c)), strings.ToUpper(string(c))
+		if lower == upper {
+			pattern += regexp.QuoteMeta(string(c))
+		} else {
+			pattern += "[" + upper + lower + "]"
+		}
+	}
+	return pattern + "$"
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for ix := range values {
+		result[ix] = values[ix]
+	}
+	return result
+}
+
+// validateSchema checks a decoded JSON value against the subset of JSON
+// Schema that Schema generates.
+func validateSchema(schema map[string]interface{}, value interface{}, path []interface{}) []Problem {
+	problems := []Problem{}
+	problem := func(format string, args ...interface{}) []Problem {
+		return append(problems, Problem{Index: -1, Path: formatPath(path), path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if branches, found := schema["anyOf"].([]interface{}); found {
+		for _, branch := range branches {
+			if len(validateSchema(branch.(map[string]interface{}), value, path)) == 0 {
+				return problems
+			}
+		}
+		return problem("doesn't match any of the allowed forms")
+	}
+	if branches, found := schema["oneOf"].([]interface{}); found {
+		// Report the problems of the closest branch.
+		var closest []Problem
+		for _, branch := range branches {
+			branchProblems := validateSchema(branch.(map[string]interface{}), value, path)
+			if len(branchProblems) == 0 {
+				return problems
+			}
+			if closest == nil || len(branchProblems) < len(closest) {
+				closest = branchProblems
+			}
+		}
+		return append(problems, closest...)
+	}
+
+	if expected, found := schema["type"]; found && !schemaTypeMatches(expected, value) {
+		return problem("expected %v", expected)
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, found := schema["required"].([]interface{}); found {
+			for _, name := range required {
+				if _, found := value[name.(string)]; !found {
+					problems = problem("%s is required", name)
+				}
+			}
+		}
+		keys := []string{}
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propertyPath := childPath(path, key)
+			if property, found := properties[key]; found {
+				problems = append(problems, validateSchema(property.(map[string]interface{}), value[key], propertyPath)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, Problem{Index: -1, Path: formatPath(propertyPath), path: propertyPath, Message: "unknown field"})
+				}
+			case map[string]interface{}:
+				problems = append(problems, validateSchema(additional, value[key], propertyPath)...)
+			}
+		}
+	case []interface{}:
+		if items, found := schema["items"].(map[string]interface{}); found {
+			for ix, item := range value {
+				problems = append(problems, validateSchema(items, item, childPath(path, ix))...)
+			}
+		}
+	case string:
+		if pattern, found := schema["pattern"].(string); found {
+			if matched, _ := regexp.MatchString(pattern, value); !matched {
+				problems = problem("'%s' doesn't match %s", value, pattern)
+			}
+		}
+	case float64:
+		if minimum, found := schema["minimum"]; found && value < toFloat(minimum) {
+			problems = problem("%v is less than %v", value, minimum)
+		}
+		if maximum, found := schema["maximum"]; found && value > toFloat(maximum) {
+			problems = problem("%v is greater than %v", value, maximum)
+		}
+	}
+	return problems
+}
+
+func schemaTypeMatches(expected interface{}, value interface{}) bool {
+	if types, ok := expected.([]interface{}); ok {
+		for _, t := range types {
+			if schemaTypeMatches(t, value) {
+				return true
+			}
+		}
+		return false
+	}
+	switch expected {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	}
+	return false
+}
+
+func toFloat(value interface{}) float64 {
+	switch value := value.(type) {
+	case int:
+		return float64(value)
+	case float64:
+		return value
+	}
+	return math.NaN()
+}
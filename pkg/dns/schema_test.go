@@ -0,0 +1,175 @@
+package dns
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+func TestPublishedSchemaIsCurrent(t *testing.T) {
+	published, err := ioutil.ReadFile("../../schema/config.schema.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	generated, err := SchemaJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(published) != string(generated) {
+		t.Errorf("schema/config.schema.json is out of date, regenerate it with 'dns-sync schema > schema/config.schema.json'")
+	}
+}
+
+func schemaProblems(t *testing.T, config string) []string {
+	jsonData, err := yaml.YAMLToJSON([]byte(config))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Round trip the schema through JSON, as an editor would see it.
+	schemaData, err := SchemaJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal(schemaData, &schema); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(jsonData, &value); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := []string{}
+	for _, problem := range validateSchema(schema, value, nil) {
+		result = append(result, problem.String())
+	}
+	return result
+}
+
+func TestValidateSchema(t *testing.T) {
+	if problems := schemaProblems(t, validConfig); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+	if problems := schemaProblems(t, defaultsConfig); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+
+	tests := []struct {
+		config   string
+		expected []string
+	}{
+		{
+			config:   strings.Replace(validConfig, "  addresses:", "  adresses:", 1),
+			expected: []string{"records[0]: addresses is required", "records[0].adresses: unknown field"},
+		},
+		{
+			config:   strings.Replace(validConfig, "ttl: 200", "ttl: soon", 1),
+			expected: []string{"records[1].ttl: doesn't match any of the allowed forms"},
+		},
+		{
+			config:   strings.Replace(validConfig, "  dnsName: sync.contuso.io.\n", "", 1),
+			expected: []string{"zone: dnsName is required"},
+		},
+		{
+			config:   strings.Replace(validConfig, "ignore:", "ignored:", 1),
+			expected: []string{"ignored: unknown field"},
+		},
+		{
+			config:   "records: []\n",
+			expected: []string{"config: zone is required"},
+		},
+	}
+	for ix, test := range tests {
+		problems := schemaProblems(t, test.config)
+		if strings.Join(problems, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("[%d] expected %v, saw %v", ix, test.expected, problems)
+		}
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `zone:
+  name: test
+  dnsName: example.com.
+include:
+- team.yaml
+records:
+- kind: A
+  ttl: 60
+  name: www.example.com.
+  addresses:
+  - 1.2.3.4
+`,
+		"team.yaml": `records:
+- kind: CNAME
+  ttl: 60
+  name: www.example.com.
+  canonicalName: other.example.com.
+- kind: A
+  ttl: 60
+  name: ftp.example.org.
+  addresses:
+  - 1.2.3.4
+`,
+	})
+	problems, err := ValidateFile(filepath.Join(dir, "config.yaml"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		filepath.Join(dir, "team.yaml") + ":6: record 2 (A ftp.example.org.): name is outside of zone example.com.",
+		filepath.Join(dir, "team.yaml") + ":2: record 1 (CNAME www.example.com.): a CNAME can't coexist with other records at the same name",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %v, saw %v", expected, problems)
+	}
+	for ix := range problems {
+		if problems[ix].String() != expected[ix] {
+			t.Errorf("expected '%s', saw '%s'", expected[ix], problems[ix])
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "team.yaml"), []byte("records:\n- kind: A\n  ttl: 60\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	problems, err = ValidateFile(filepath.Join(dir, "config.yaml"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Line != 2 || problems[0].Path != "records[0]" {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestValidateFileLinesWithVariables(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `variables:
+  env: staging
+
+zone:
+  name: ${env}
+  dnsName: example.com.
+
+# Blank lines and comments are lost when the variables are expanded.
+
+records:
+
+- kind: A
+  name: www.${env}.example.com.
+
+  addresses: [1.2.3.4]
+  ttl: -5
+`,
+	})
+	problems, err := ValidateFile(filepath.Join(dir, "config.yaml"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Line != 16 || problems[0].Path != "records[0].ttl" {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// SOA holds the SOA settings of a zone. Fields that aren't set keep the
+// provider's value.
+type SOA struct {
+	// Email is the contact address, e.g. hostmaster@example.com.
+	Email   string `json:"email,omitempty" yaml:"email,omitempty"`
+	Refresh TTL    `json:"refresh,omitempty" yaml:"refresh,omitempty"`
+	Retry   TTL    `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Expire  TTL    `json:"expire,omitempty" yaml:"expire,omitempty"`
+	// NegativeTTL is how long resolvers cache a missing name, which is the
+	// minimum field of the SOA record.
+	NegativeTTL TTL `json:"negativeTTL,omitempty" yaml:"negativeTTL,omitempty"`
+	// TTL is the TTL of the SOA record itself.
+	TTL TTL `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+}
+
+// soaEmail converts an email address to the form used in SOA records, e.g.
+// host.master@example.com becomes host\.master.example.com. Values without
+// an @ are assumed to be in that form already.
+func soaEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return canonicalName(email)
+	}
+	local := strings.Replace(email[:at], ".", "\\.", -1)
+	return canonicalName(local + "." + email[at+1:])
+}
+
+// apply returns a copy of record with the fields that are set in s changed.
+func (s SOA) apply(record SOARecord) SOARecord {
+	if len(s.Email) > 0 {
+		record.Email = soaEmail(s.Email)
+	}
+	if s.Refresh > 0 {
+		record.Refresh = s.Refresh
+	}
+	if s.Retry > 0 {
+		record.Retry = s.Retry
+	}
+	if s.Expire > 0 {
+		record.Expire = s.Expire
+	}
+	if s.NegativeTTL > 0 {
+		record.Minimum = s.NegativeTTL
+	}
+	if s.TTL > 0 {
+		record.TTL = s.TTL
+	}
+	return record
+}
+
+// ParseSOA parses the record data of an SOA record, e.g.
+// "ns1.example.com. hostmaster.example.com. 1 21600 3600 259200 300".
+func ParseSOA(base BaseRecord, rrdata string) (SOARecord, error) {
+	fields := strings.Fields(rrdata)
+	if len(fields) != 7 {
+		return SOARecord{}, fmt.Errorf("expected 7 fields in SOA data, saw: %s", rrdata)
+	}
+	numbers := make([]int64, 5)
+	for ix := range numbers {
+		number, err := strconv.ParseInt(fields[ix+2], 10, 64)
+		if err != nil {
+			return SOARecord{}, fmt.Errorf("bad SOA data '%s': %v", rrdata, err)
+		}
+		numbers[ix] = number
+	}
+	return SOARecord{
+		BaseRecord: base,
+		Nameserver: fields[0],
+		Email:      fields[1],
+		Serial:     numbers[0],
+		Refresh:    TTL(numbers[1]),
+		Retry:      TTL(numbers[2]),
+		Expire:     TTL(numbers[3]),
+		Minimum:    TTL(numbers[4]),
+	}, nil
+}
+
+// planSOA adds the update that applies the zone's soa settings to the
+// existing SOA record. There is never more than one SOA record, so it is
+// only ever updated, never created or deleted.
+func (p *Plan) planSOA(zone Zone, existingRecords []Record, ignored []ignoreMatcher, options Options) {
+	if zone.SOA == nil {
+		return
+	}
+	var existing *SOARecord
+	for _, record := range existingRecords {
+		if soa, ok := record.(SOARecord); ok {
+			existing = &soa
+			break
+		}
+	}
+	if existing == nil {
+		glog.Warningf("Zone %s has no SOA record, not applying soa settings.", zone.Name)
+		return
+	}
+	// Ignored SOA records are reported along with the other existing records.
+	if len(ignoreReason(ignored, *existing)) > 0 {
+		return
+	}
+	desired := zone.SOA.apply(*existing)
+	if !recordIsDifferent(desired, *existing) {
+		return
+	}
+	if !options.Policy.allowsUpdate() {
+		p.skip(*existing, "policy %s doesn't allow updates", options.Policy)
+		return
+	}
+	p.Changes = append(p.Changes, Change{Action: ActionUpdate, Existing: *existing, Desired: desired})
+}
@@ -0,0 +1,142 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestParseSOA(t *testing.T) {
+	base := BaseRecord{Name: "example.com.", TTL: 21600}
+	soa, err := ParseSOA(base, "ns1.example.com. hostmaster.example.com. 7 21600 3600 259200 300")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if soa.Nameserver != "ns1.example.com." || soa.Email != "hostmaster.example.com." || soa.Serial != 7 ||
+		soa.Refresh != 21600 || soa.Retry != 3600 || soa.Expire != 259200 || soa.Minimum != 300 {
+		t.Errorf("unexpected record: %v", soa)
+	}
+	if soa.RRData()[0] != "ns1.example.com. hostmaster.example.com. 7 21600 3600 259200 300" {
+		t.Errorf("unexpected rrdata: %v", soa.RRData())
+	}
+
+	for _, rrdata := range []string{"ns1.example.com. hostmaster.example.com. 7", "ns1. host. a b c d e"} {
+		if _, err := ParseSOA(base, rrdata); err == nil {
+			t.Errorf("expected an error for '%s'", rrdata)
+		}
+	}
+}
+
+func TestSOAEmail(t *testing.T) {
+	tests := map[string]string{
+		"hostmaster@example.com":     "hostmaster.example.com.",
+		"host.master@example.com":    "host\\.master.example.com.",
+		"hostmaster.example.com.":    "hostmaster.example.com.",
+		"Hostmaster@Example.com":     "hostmaster.example.com.",
+		"hostmaster@bücher.example.": "hostmaster.xn--bcher-kva.example.",
+	}
+	for email, expected := range tests {
+		if value := soaEmail(email); value != expected {
+			t.Errorf("expected %s for '%s', saw %s", expected, email, value)
+		}
+	}
+}
+
+func TestSyncSOA(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// Providers create an SOA record along with the zone.
+	soa := SOARecord{
+		BaseRecord: BaseRecord{Name: "example.com.", TTL: 21600},
+		Nameserver: "ns1.provider.net.",
+		Email:      "hostmaster.provider.net.",
+		Serial:     1,
+		Refresh:    21600,
+		Retry:      3600,
+		Expire:     259200,
+		Minimum:    3600,
+	}
+	svc.RecordMap[zone.Name][recordKey(soa)] = soa
+
+	// Without soa settings the SOA record is left alone.
+	plan, err := Sync(svc, zone, records, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("expected no changes, saw: %v", plan)
+	}
+
+	zone.SOA = &SOA{Email: "dns@example.com", NegativeTTL: 60}
+	plan, err = Sync(svc, zone, records, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != ActionUpdate {
+		t.Fatalf("expected an SOA update, saw: %v", plan)
+	}
+	updated := svc.RecordMap[zone.Name][recordKey(soa)].(SOARecord)
+	if updated.Email != "dns.example.com." || updated.Minimum != 60 || updated.Refresh != 21600 || updated.Nameserver != soa.Nameserver {
+		t.Errorf("unexpected SOA record: %v", updated)
+	}
+
+	// A provider bumping the serial isn't a change.
+	updated.Serial = 2
+	svc.RecordMap[zone.Name][recordKey(soa)] = updated
+	if plan, err = Sync(svc, zone, records, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("expected no changes, saw: %v", plan)
+	}
+
+	// Removing every record never deletes the SOA record.
+	if plan, err = Sync(svc, zone, []Record{}, Options{Force: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, found := svc.RecordMap[zone.Name][recordKey(soa)]; !found {
+		t.Errorf("expected the SOA record to be kept")
+	}
+
+	// Updates are a policy decision like any other.
+	zone.SOA.NegativeTTL = 30
+	if plan, err = Sync(svc, zone, []Record{}, Options{Policy: PolicyCreateOnly}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 0 || len(plan.Skipped) != 1 {
+		t.Errorf("expected the SOA update to be skipped, saw: %v", plan)
+	}
+}
+
+func TestValidateSOA(t *testing.T) {
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+		SOA:     &SOA{Email: "dns@example.com", Retry: -1},
+	}
+	records := []Record{
+		SOARecord{
+			BaseRecord: BaseRecord{Name: "example.com.", TTL: 300},
+			Nameserver: "ns1.example.com.",
+			Email:      "dns.example.com.",
+		},
+	}
+	err := Validate(zone, records)
+	validationErr, ok := err.(*ValidationError)
+	if !ok || len(validationErr.Problems) != 2 {
+		t.Errorf("expected two problems, saw: %v", err)
+	}
+}
@@ -1,106 +1,207 @@
 package dns
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/golang/glog"
 )
 
-func Sync(service Service, zone Zone, records []Record) error {
-	glog.Info("Syncing zones.")
-	if err := syncZone(service, zone); err != nil {
-		return err
+// Options controls how Sync reconciles a zone. The zero value is a full sync.
+type Options struct {
+	// Policy limits the kinds of record changes that are made.
+	Policy Policy
+	// OwnerID, if set, enables ownership tracking. Records are marked as
+	// owned by OwnerID, and records owned by anyone else are never
+	// overwritten or deleted. Without an owner ID, records that have an
+	// owner are never overwritten or deleted.
+	OwnerID string
+	// Ignore lists records that are never created, updated or deleted.
+	Ignore []IgnoreRule
+	// Limits aborts the sync before any change is made if the plan is too
+	// large, unless Force is set.
+	Limits Limits
+	Force  bool
+	// RecordsOnly assumes the zone exists, looks it up by its DNS name and
+	// only changes its records, never the zone itself.
+	RecordsOnly bool
+	// ContinueOnError keeps applying changes after one fails, instead of
+	// stopping, except for those that depend on a failed change, and returns
+	// a *ChangesFailedError listing every failure.
+	ContinueOnError bool
+	// Concurrency is how many changes of the same stage are made at a time,
+	// 1 if it isn't set.
+	Concurrency int
+}
+
+// Sync makes the zone and its records match the config and returns the plan
+// along with the outcome of every change. The config is validated before any
+// provider call. Once there is a plan, a result is returned even on errors.
+func Sync(service Service, zone Zone, records []Record, options Options) (*Result, error) {
+	started := time.Now()
+	if err := Validate(zone, records); err != nil {
+		return nil, err
+	}
+	plan, existingZone, err := planSync(service, zone, records, options)
+	if err != nil {
+		return nil, err
 	}
-	glog.Info("Syncing records.")
-	if err := syncRecords(service, zone, records); err != nil {
-		return err
+	result := &Result{Plan: plan, Started: started}
+	finish := func(err error) (*Result, error) {
+		result.Duration = time.Since(started)
+		return result, err
+	}
+	// The limits are checked before any write, including creating the zone.
+	if !options.Force {
+		if err := options.Limits.check(plan); err != nil {
+			return finish(err)
+		}
 	}
-	return nil
+	if plan.CreateZone {
+		glog.Info("Creating new zone.")
+		if err := service.WriteZone(managedZone(zone, options.OwnerID), true); err != nil {
+			return finish(err)
+		}
+		// The provider may have created records along with the zone, e.g. the
+		// SOA and apex NS records, so the records are planned again.
+		if plan, _, err = planSync(service, zone, records, options); err != nil {
+			return finish(err)
+		}
+		plan.CreateZone = true
+		result.Plan = plan
+	} else if !options.RecordsOnly && !zonesEqual(zone, *existingZone) {
+		glog.V(2).Info("Updating zone.")
+		zone.Managed, zone.Owner = existingZone.Managed, existingZone.Owner
+		if err := service.WriteZone(zone, false); err != nil {
+			return finish(err)
+		}
+	}
+	result.Applied, err = plan.Apply(service, options)
+	return finish(err)
 }
 
-func syncZone(service Service, zone Zone) error {
-	currentZones, err := service.Zones()
+// PlanSync returns the plan that Sync would carry out, without changing
+// anything. It fails like Sync if the plan exceeds the limits.
+func PlanSync(service Service, zone Zone, records []Record, options Options) (*Plan, error) {
+	if err := Validate(zone, records); err != nil {
+		return nil, err
+	}
+	plan, _, err := planSync(service, zone, records, options)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	glog.V(2).Infof("Current zones: %v\n", currentZones)
+	if !options.Force {
+		if err := options.Limits.check(plan); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// Check returns the changes that Sync would make, which is the drift between
+// the config and the live zone, without making any. The limits don't apply.
+func Check(service Service, zone Zone, records []Record, options Options) (*Plan, error) {
+	options.Force = true
+	return PlanSync(service, zone, records, options)
+}
+
+// planSync looks up the zone and computes the plan for its records, without
+// changing anything. It returns the zone as it exists in the provider, or nil
+// if it doesn't exist yet.
+func planSync(service Service, zone Zone, records []Record, options Options) (*Plan, *Zone, error) {
 	var existingZone *Zone
-	for ix := range currentZones {
-		if currentZones[ix].Name == zone.Name {
-			existingZone = &currentZones[ix]
+	var err error
+	if options.RecordsOnly {
+		if existingZone, err = findZoneByDNSName(service, zone.DNSName); err != nil {
+			return nil, nil, err
+		}
+		if existingZone == nil {
+			return nil, nil, fmt.Errorf("zone %s doesn't exist, and records-only mode never creates zones", zone.DNSName)
 		}
+		// Use the provider's zone, which may be named differently.
+		soa := zone.SOA
+		zone = *existingZone
+		zone.SOA = soa
+	} else if existingZone, err = findZone(service, zone); err != nil {
+		return nil, nil, err
 	}
-	if existingZone == nil {
-		glog.V(2).Info("Creating new zone.")
-		return service.WriteZone(zone, true)
+
+	glog.Info("Syncing records.")
+	existingRecords := []Record{}
+	if existingZone != nil {
+		if existingRecords, err = service.Records(zone); err != nil {
+			return nil, nil, err
+		}
 	}
-	if !zonesEqual(zone, *existingZone) {
-		glog.V(2).Info("Updating zone.")
-		return service.WriteZone(zone, false)
+	glog.V(2).Infof("Current records: %v", existingRecords)
+	// The nameservers the zone is served from are protected, which are the
+	// config's only if the zone is about to be created with them.
+	nameservers := zone.Nameservers
+	if existingZone != nil {
+		nameservers = existingZone.Nameservers
+	}
+	plan, err := computePlan(zone, existingRecords, records, nameservers, options)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	plan.CreateZone = existingZone == nil
+	return plan, existingZone, nil
 }
 
-func syncRecords(service Service, zone Zone, records []Record) error {
-	existingRecords, err := service.Records(zone)
+func findZone(service Service, zone Zone) (*Zone, error) {
+	currentZones, err := service.Zones()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	glog.V(2).Infof("Current records: %v", existingRecords)
-	for _, record := range records {
-		existingRecord := findRecord(record.RecordName(), existingRecords)
-		if existingRecord != nil {
-			if recordIsDifferent(record, *existingRecord) {
-				glog.V(2).Infof("Updating record: %v", record)
-				if err := service.WriteRecord(zone, *existingRecord, record); err != nil {
-					return err
-				}
-			}
-		} else {
-			glog.V(2).Infof("Creating record: %v", record)
-			if err := service.WriteRecord(zone, nil, record); err != nil {
-				return err
-			}
+	glog.V(2).Infof("Current zones: %v\n", currentZones)
+	for ix := range currentZones {
+		if currentZones[ix].Name == zone.Name {
+			return &currentZones[ix], nil
 		}
 	}
-	for _, record := range existingRecords {
-		desiredRecord := findRecord(record.RecordName(), records)
-		// Maintain the apex NS record no matter what.
-		if record.RecordName() == zone.DNSName {
-			continue
-		}
+	return nil, nil
+}
 
-		if desiredRecord == nil {
-			if err := service.DeleteRecord(zone, record); err != nil {
-				return err
-			}
+func findZoneByDNSName(service Service, dnsName string) (*Zone, error) {
+	currentZones, err := service.Zones()
+	if err != nil {
+		return nil, err
+	}
+	for ix := range currentZones {
+		if canonicalName(currentZones[ix].DNSName) == canonicalName(dnsName) {
+			return &currentZones[ix], nil
 		}
 	}
-	return nil
+	return nil, nil
 }
 
-func findRecord(name string, records []Record) *Record {
-	if len(records) == 0 {
-		return nil
-	}
-	for ix := range records {
-		if records[ix].RecordName() == name {
-			return &records[ix]
+// recordsByKey indexes records by their name and type, keeping the first
+// record set for each, so that large zones are matched without comparing
+// every pair of records.
+func recordsByKey(records []Record) map[string]Record {
+	result := make(map[string]Record, len(records))
+	for _, record := range records {
+		key := recordKey(record)
+		if _, found := result[key]; !found {
+			result[key] = record
 		}
 	}
-	return nil
+	return result
 }
 
+// recordIsDifferent compares the canonical forms of two records.
 func recordIsDifferent(r1 Record, r2 Record) bool {
-	if r1.RecordName() != r2.RecordName() {
+	if canonicalName(r1.RecordName()) != canonicalName(r2.RecordName()) {
 		return true
 	}
 	if r1.TimeToLive() != r2.TimeToLive() {
 		return true
 	}
-	if r1.Type() != r2.Type() {
+	if canonicalType(r1.Type()) != canonicalType(r2.Type()) {
 		return true
 	}
-	rr1 := r1.RRData()
-	rr2 := r2.RRData()
+	rr1 := r1.CanonicalRRData()
+	rr2 := r2.CanonicalRRData()
 	if len(rr1) != len(rr2) {
 		return true
 	}
@@ -114,13 +215,21 @@ func recordIsDifferent(r1 Record, r2 Record) bool {
 
 func zonesEqual(z1 Zone, z2 Zone) bool {
 	if z1.Name != z2.Name ||
-		z1.DNSName != z2.DNSName ||
-		z1.Description != z2.Description ||
-		len(z1.Nameservers) != len(z2.Nameservers) {
+		canonicalName(z1.DNSName) != canonicalName(z2.DNSName) ||
+		z1.Description != z2.Description {
+		return false
+	}
+	// Zones that don't list nameservers use the ones the provider assigned.
+	if len(z1.Nameservers) == 0 {
+		return true
+	}
+	ns1 := canonicalSet(z1.Nameservers, canonicalName)
+	ns2 := canonicalSet(z2.Nameservers, canonicalName)
+	if len(ns1) != len(ns2) {
 		return false
 	}
-	for ix := range z1.Nameservers {
-		if z1.Nameservers[ix] != z2.Nameservers[ix] {
+	for ix := range ns1 {
+		if ns1[ix] != ns2[ix] {
 			return false
 		}
 	}
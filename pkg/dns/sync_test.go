@@ -44,7 +44,7 @@ func TestSyncZoneAndRecordCreate(t *testing.T) {
 		},
 	}
 
-	if err := Sync(svc, zone, records); err != nil {
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
@@ -52,7 +52,7 @@ func TestSyncZoneAndRecordCreate(t *testing.T) {
 		t.Errorf("expected zone '%s' to exist in %v", zone.Name, svc.ZoneMap)
 	}
 
-	recordsOut, err := svc.Records(zone.Name)
+	recordsOut, err := svc.Records(zone)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -82,7 +82,7 @@ func TestSyncZoneUpdate(t *testing.T) {
 		},
 	}
 
-	if err := syncZone(svc, zone); err != nil {
+	if _, err := Sync(svc, zone, []Record{}, Options{}); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
@@ -131,7 +131,7 @@ func TestRecordUpdate(t *testing.T) {
 		},
 	}
 
-	if err := Sync(svc, zone, records); err != nil {
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
@@ -143,11 +143,11 @@ func TestRecordUpdate(t *testing.T) {
 		CanonicalName: "alternative.else.com",
 	}
 
-	if err := Sync(svc, zone, records); err != nil {
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	recordsOut, err := svc.Records(zone.Name)
+	recordsOut, err := svc.Records(zone)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -194,7 +194,7 @@ func TestRecordDelete(t *testing.T) {
 		},
 	}
 
-	if err := Sync(svc, zone, records); err != nil {
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
@@ -203,11 +203,11 @@ func TestRecordDelete(t *testing.T) {
 		records[2],
 	}
 
-	if err := Sync(svc, zone, records); err != nil {
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	recordsOut, err := svc.Records(zone.Name)
+	recordsOut, err := svc.Records(zone)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -218,6 +218,728 @@ func TestRecordDelete(t *testing.T) {
 	expectRecordSetsEqual(records, recordsOut, t)
 }
 
+func TestRecordIsDifferentCanonical(t *testing.T) {
+	tests := []struct {
+		r1        Record
+		r2        Record
+		different bool
+	}{
+		{
+			r1:        AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", Kind: "A", TTL: 25}, Addresses: []string{"1.2.3.4", "2.3.4.5"}},
+			r2:        AddressRecord{BaseRecord: BaseRecord{Name: "WWW.example.com", Kind: "a", TTL: 25}, Addresses: []string{"2.3.4.5", "1.2.3.4"}},
+			different: false,
+		},
+		{
+			r1:        AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"2001:db8::1"}},
+			r2:        AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"2001:0DB8:0:0:0:0:0:1"}},
+			different: false,
+		},
+		{
+			r1:        CNameRecord{BaseRecord: BaseRecord{Name: "cname.example.com.", TTL: 125}, CanonicalName: "Somewhere.Else.com"},
+			r2:        CNameRecord{BaseRecord: BaseRecord{Name: "cname.example.com.", TTL: 125}, CanonicalName: "somewhere.else.com."},
+			different: false,
+		},
+		{
+			r1:        NSRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 525}, Nameservers: []string{"ns2.company.com.", "ns1.company.com."}},
+			r2:        NSRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 525}, Nameservers: []string{"NS1.company.com", "ns2.company.com"}},
+			different: false,
+		},
+		{
+			r1:        AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4", "2.3.4.5"}},
+			r2:        AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4"}},
+			different: true,
+		},
+		{
+			r1:        CNameRecord{BaseRecord: BaseRecord{Name: "cname.example.com.", TTL: 125}, CanonicalName: "somewhere.else.com."},
+			r2:        CNameRecord{BaseRecord: BaseRecord{Name: "cname.example.com.", TTL: 125}, CanonicalName: "elsewhere.com."},
+			different: true,
+		},
+		{
+			r1:        CNameRecord{BaseRecord: BaseRecord{Name: "cname.example.com.", TTL: 125}, CanonicalName: "somewhere.else.com."},
+			r2:        CNameRecord{BaseRecord: BaseRecord{Name: "cname.example.com.", TTL: 126}, CanonicalName: "somewhere.else.com."},
+			different: true,
+		},
+	}
+	for ix, test := range tests {
+		if different := recordIsDifferent(test.r1, test.r2); different != test.different {
+			t.Errorf("[%d] expected different=%v for %v vs %v", ix, test.different, test.r1, test.r2)
+		}
+	}
+}
+
+func TestSyncIsNoOpForEquivalentRecords(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{
+				"1.2.3.4",
+				"2.3.4.5",
+			},
+		},
+	}
+
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Simulate a provider that reorders addresses and drops the trailing dot.
+	reordered := AddressRecord{
+		BaseRecord: BaseRecord{
+			Name: "www.example.com",
+			TTL:  25,
+		},
+		Addresses: []string{
+			"2.3.4.5",
+			"1.2.3.4",
+		},
+	}
+	svc.RecordMap[zone.Name] = FakeRecords{recordKey(reordered): reordered}
+
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	stored := svc.RecordMap[zone.Name][recordKey(reordered)].(AddressRecord)
+	if stored.Name != reordered.Name || stored.Addresses[0] != "2.3.4.5" {
+		t.Errorf("expected record to be left alone, found %v", stored)
+	}
+}
+
+func TestSyncPolicies(t *testing.T) {
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	existing := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+		CNameRecord{
+			BaseRecord: BaseRecord{
+				Name: "manual.example.com.",
+				TTL:  125,
+			},
+			CanonicalName: "somewhere.else.com.",
+		},
+	}
+	desired := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"2.3.4.5"},
+		},
+		CNameRecord{
+			BaseRecord: BaseRecord{
+				Name: "new.example.com.",
+				TTL:  125,
+			},
+			CanonicalName: "somewhere.else.com.",
+		},
+	}
+
+	tests := []struct {
+		policy   Policy
+		expected []Record
+	}{
+		{
+			policy:   PolicySync,
+			expected: desired,
+		},
+		{
+			policy:   PolicyUpsertOnly,
+			expected: []Record{desired[0], desired[1], existing[1]},
+		},
+		{
+			policy:   PolicyCreateOnly,
+			expected: []Record{existing[0], desired[1], existing[1]},
+		},
+	}
+	for _, test := range tests {
+		svc := &FakeDNSService{}
+		if _, err := Sync(svc, zone, existing, Options{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := Sync(svc, zone, desired, Options{Policy: test.policy}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		recordsOut, err := svc.Records(zone)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		expectRecordSetsEqual(test.expected, recordsOut, t)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, name := range []string{"", "sync", "upsert-only", "create-only"} {
+		if _, err := ParsePolicy(name); err != nil {
+			t.Errorf("unexpected error for %q: %v", name, err)
+		}
+	}
+	if _, err := ParsePolicy("delete-everything"); err == nil {
+		t.Errorf("expected error for unknown policy")
+	}
+}
+
+func TestSyncOwnership(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	teamA := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "a.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	teamB := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "b.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"2.3.4.5"},
+		},
+	}
+
+	if _, err := Sync(svc, zone, teamA, Options{OwnerID: "team-a"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Sync(svc, zone, teamB, Options{OwnerID: "team-b"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := append(append([]Record{}, teamA...), teamB...)
+	expected = append(expected, ownerRecords(teamA, "team-a")...)
+	expected = append(expected, ownerRecords(teamB, "team-b")...)
+	expectRecordSetsEqual(expected, recordsOut, t)
+
+	// Team B tries to take over team A's record.
+	conflicting := append([]Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "a.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"9.9.9.9"},
+		},
+	}, teamB...)
+	_, err = Sync(svc, zone, conflicting, Options{OwnerID: "team-b"})
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("expected conflict error, saw: %v", err)
+	}
+	recordsOut, err = svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(expected, recordsOut, t)
+
+	// Team A removes its record, which shouldn't touch team B's.
+	if _, err := Sync(svc, zone, []Record{}, Options{OwnerID: "team-a"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	recordsOut, err = svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(append(append([]Record{}, teamB...), ownerRecords(teamB, "team-b")...), recordsOut, t)
+}
+
+func TestSyncOwnershipLeavesUnownedRecords(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	manual := []Record{
+		CNameRecord{
+			BaseRecord: BaseRecord{
+				Name: "manual.example.com.",
+				TTL:  125,
+			},
+			CanonicalName: "somewhere.else.com.",
+		},
+	}
+	if _, err := Sync(svc, zone, manual, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Sync(svc, zone, []Record{}, Options{OwnerID: "team-a"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(manual, recordsOut, t)
+}
+
+func TestSyncWithoutOwnerIDLeavesOwnedRecords(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	owned := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "a.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	if _, err := Sync(svc, zone, owned, Options{OwnerID: "team-a"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := append(append([]Record{}, owned...), ownerRecords(owned, "team-a")...)
+
+	// A run without an owner ID neither deletes nor overwrites them.
+	changed := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "a.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"9.9.9.9"},
+		},
+	}
+	for _, records := range [][]Record{{}, changed} {
+		result, err := Sync(svc, zone, records, Options{})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(result.Changes) != 0 || len(result.Skipped) == 0 {
+			t.Errorf("expected the owned records to be skipped, saw: %v", result.Plan)
+		}
+		recordsOut, err := svc.Records(zone)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		expectRecordSetsEqual(expected, recordsOut, t)
+	}
+}
+
+func TestSyncOwnershipSkipsIgnoredRecords(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "a.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "ignored.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"2.3.4.5"},
+		},
+	}
+	options := Options{OwnerID: "team-a", Ignore: []IgnoreRule{{Name: "ignored.*"}}}
+	if _, err := Sync(svc, zone, records, options); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(append(records[:1:1], ownerRecords(records[:1], "team-a")...), recordsOut, t)
+}
+
+func TestSyncIgnoreRules(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	external := []Record{
+		TXTRecord{
+			BaseRecord: BaseRecord{
+				Name: "_acme-challenge.www.example.com.",
+				TTL:  60,
+				Kind: "TXT",
+			},
+			Text: []string{"token"},
+		},
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "app.k8s.example.com.",
+				TTL:  60,
+				Kind: "A",
+			},
+			Addresses: []string{"10.0.0.1"},
+		},
+	}
+	if _, err := Sync(svc, zone, external, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	desired := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+				Kind: "A",
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "other.k8s.example.com.",
+				TTL:  25,
+				Kind: "A",
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	options := Options{
+		Ignore: []IgnoreRule{
+			{Name: "_acme-challenge.*", Reason: "cert-manager"},
+			{NameRegex: `\.k8s\.example\.com\.$`, Kind: "A"},
+		},
+	}
+	plan, err := Sync(svc, zone, desired, options)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != ActionCreate {
+		t.Errorf("unexpected changes: %v", plan.Changes)
+	}
+	if len(plan.Skipped) != 3 {
+		t.Errorf("expected three skipped records: %v", plan.Skipped)
+	}
+
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(append(append([]Record{}, external...), desired[0]), recordsOut, t)
+}
+
+func TestCompileIgnoreRulesErrors(t *testing.T) {
+	rules := [][]IgnoreRule{
+		{{}},
+		{{Name: "[bad"}},
+		{{NameRegex: "(bad"}},
+	}
+	for _, rule := range rules {
+		if _, err := compileIgnoreRules(rule); err == nil {
+			t.Errorf("expected error for %v", rule)
+		}
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	plan, err := PlanSync(svc, zone, records, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 1 {
+		t.Errorf("unexpected changes: %v", plan.Changes)
+	}
+	if len(svc.ZoneMap) != 0 {
+		t.Errorf("expected no zones to be created: %v", svc.ZoneMap)
+	}
+}
+
+func TestSyncLimits(t *testing.T) {
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		records = append(records, AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: name + ".example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		})
+	}
+
+	tests := []struct {
+		limits  Limits
+		force   bool
+		desired []Record
+		abort   bool
+	}{
+		{
+			limits:  Limits{MaxDeletePercent: 50},
+			desired: []Record{},
+			abort:   true,
+		},
+		{
+			limits:  Limits{MaxDeletePercent: 50},
+			force:   true,
+			desired: []Record{},
+			abort:   false,
+		},
+		{
+			limits:  Limits{MaxDeletePercent: 50},
+			desired: records[2:],
+			abort:   false,
+		},
+		{
+			limits:  Limits{MaxDeletes: 1},
+			desired: records[2:],
+			abort:   true,
+		},
+		{
+			limits:  Limits{MaxChanges: 2},
+			desired: records[:1],
+			abort:   true,
+		},
+		{
+			limits:  Limits{MaxChangePercent: 75},
+			desired: records[:1],
+			abort:   false,
+		},
+	}
+	for ix, test := range tests {
+		svc := &FakeDNSService{}
+		if _, err := Sync(svc, zone, records, Options{}); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ix, err)
+		}
+		_, err := Sync(svc, zone, test.desired, Options{Limits: test.limits, Force: test.force})
+		recordsOut, _ := svc.Records(zone)
+		if test.abort {
+			if _, ok := err.(*LimitError); !ok {
+				t.Errorf("[%d] expected limit error, saw: %v", ix, err)
+			}
+			expectRecordSetsEqual(records, recordsOut, t)
+		} else {
+			if err != nil {
+				t.Errorf("[%d] unexpected error: %v", ix, err)
+			}
+			expectRecordSetsEqual(test.desired, recordsOut, t)
+		}
+	}
+
+	// The SOA record doesn't count towards the percentages.
+	svc := &FakeDNSService{}
+	if _, err := Sync(svc, zone, records[:3], Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	soa := SOARecord{BaseRecord: BaseRecord{Name: "example.com.", TTL: 300}, Nameserver: "ns1.provider.net.", Email: "hostmaster.provider.net."}
+	svc.RecordMap[zone.Name][recordKey(soa)] = soa
+	if _, err := Sync(svc, zone, records[:1], Options{Limits: Limits{MaxDeletePercent: 50}}); err == nil {
+		t.Errorf("expected deleting 2 of 3 records to exceed the limit")
+	}
+
+	// Ownership records don't count towards the limits.
+	svc = &FakeDNSService{}
+	if _, err := Sync(svc, zone, records[:1], Options{OwnerID: "team", Limits: Limits{MaxChanges: 1}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Sync(svc, zone, records[1:2], Options{OwnerID: "team", Limits: Limits{MaxDeletes: 1, MaxChanges: 2, MaxDeletePercent: 100}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// A sync that exceeds the limits doesn't create the zone either.
+	svc = &FakeDNSService{}
+	_, err := Sync(svc, zone, records, Options{Limits: Limits{MaxChanges: 2}})
+	if _, ok := err.(*LimitError); !ok {
+		t.Errorf("expected limit error, saw: %v", err)
+	}
+	if len(svc.ZoneMap) != 0 {
+		t.Errorf("expected no zones to be created: %v", svc.ZoneMap)
+	}
+}
+
+func TestSyncReplaceAddressWithCName(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	address := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	alias := []Record{
+		CNameRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			CanonicalName: "somewhere.else.com.",
+		},
+	}
+	if _, err := Sync(svc, zone, address, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Sync(svc, zone, alias, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(alias, recordsOut, t)
+}
+
+func TestOrderChanges(t *testing.T) {
+	record := func(kind, name, data string) Record {
+		base := BaseRecord{Name: name, TTL: 25}
+		switch kind {
+		case "CNAME":
+			return CNameRecord{BaseRecord: base, CanonicalName: data}
+		case "NS":
+			return NSRecord{BaseRecord: base, Nameservers: []string{data}}
+		}
+		return AddressRecord{BaseRecord: base, Addresses: []string{data}}
+	}
+	changes := []Change{
+		{Action: ActionDelete, Existing: record("NS", "old.example.com.", "ns.old.com.")},
+		{Action: ActionCreate, Desired: record("NS", "sub.example.com.", "ns.hoster.com.")},
+		{Action: ActionCreate, Desired: record("CNAME", "a.example.com.", "b.example.com.")},
+		{Action: ActionCreate, Desired: record("CNAME", "b.example.com.", "c.example.com.")},
+		{Action: ActionDelete, Existing: record("A", "gone.example.com.", "1.2.3.4")},
+		{Action: ActionCreate, Desired: record("A", "c.example.com.", "1.2.3.4")},
+		{Action: ActionCreate, Desired: record("CNAME", "www.example.com.", "c.example.com.")},
+		{Action: ActionDelete, Existing: record("A", "www.example.com.", "1.2.3.4")},
+	}
+	expected := []string{
+		"[0] delete A www.example.com.",
+		"[1] create A c.example.com.",
+		"[2] create CNAME b.example.com.",
+		"[2] create CNAME www.example.com.",
+		"[3] create CNAME a.example.com.",
+		"[4] create NS sub.example.com.",
+		"[5] delete A gone.example.com.",
+		"[6] delete NS old.example.com.",
+	}
+	ordered := orderChanges(changes)
+	if len(ordered) != len(expected) {
+		t.Fatalf("unexpected changes: %v", ordered)
+	}
+	for ix := range ordered {
+		if ordered[ix].String() != expected[ix] {
+			t.Errorf("[%d] expected %s, saw %s", ix, expected[ix], ordered[ix])
+		}
+	}
+}
+
+func TestSyncWildcards(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{
+		CNameRecord{
+			BaseRecord: BaseRecord{
+				Name: "*.tenants.example.com.",
+				TTL:  25,
+			},
+			CanonicalName: "ingress.example.com.",
+		},
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "ingress.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	if _, err := Sync(svc, zone, records, Options{OwnerID: "tenants"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	recordsOut, err := svc.Records(zone)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(append(append([]Record{}, records...), ownerRecords(records, "tenants")...), recordsOut, t)
+	owners := recordOwners(recordsOut)
+	if owners["*.tenants.example.com."] != "tenants" {
+		t.Errorf("expected the wildcard to be owned: %v", owners)
+	}
+
+	plan, err := Sync(svc, zone, records[1:], Options{OwnerID: "tenants"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 2 {
+		t.Errorf("expected the wildcard and its owner record to be deleted: %v", plan.Changes)
+	}
+}
+
+func TestOrderChangesWildcards(t *testing.T) {
+	changes := []Change{
+		{Action: ActionCreate, Desired: CNameRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, CanonicalName: "app.tenants.example.com."}},
+		{Action: ActionCreate, Desired: CNameRecord{BaseRecord: BaseRecord{Name: "*.tenants.example.com.", TTL: 25}, CanonicalName: "ingress.example.com."}},
+	}
+	ordered := orderChanges(changes)
+	if ordered[0].Desired.RecordName() != "*.tenants.example.com." || ordered[1].Stage <= ordered[0].Stage {
+		t.Errorf("expected the wildcard to be created first: %v", ordered)
+	}
+}
+
+func TestOrderChangesOwnerRecords(t *testing.T) {
+	www := AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4"}}
+	ftp := AddressRecord{BaseRecord: BaseRecord{Name: "ftp.example.com.", TTL: 25}, Addresses: []string{"1.2.3.4"}}
+	changes := []Change{
+		{Action: ActionCreate, Desired: www},
+		{Action: ActionCreate, Desired: ownerRecords([]Record{www}, "team")[0]},
+		{Action: ActionDelete, Existing: ownerRecords([]Record{ftp}, "team")[0]},
+		{Action: ActionDelete, Existing: ftp},
+	}
+	ordered := orderChanges(changes)
+	for ix, name := range []string{"_dns-sync.www.example.com.", "www.example.com.", "ftp.example.com.", "_dns-sync.ftp.example.com."} {
+		if ordered[ix].record().RecordName() != name || ordered[ix].Stage != ix {
+			t.Errorf("[%d] expected %s in stage %d, saw: %v", ix, name, ix, ordered[ix])
+		}
+	}
+}
+
 func expectRecordSetsEqual(r1 []Record, r2 []Record, t *testing.T) {
 	if len(r1) != len(r2) {
 		t.Errorf("unexpected record set: %v vs %v", r1, r2)
@@ -225,12 +947,112 @@ func expectRecordSetsEqual(r1 []Record, r2 []Record, t *testing.T) {
 	}
 	recordMap := map[string]Record{}
 	for _, record := range r1 {
-		recordMap[record.RecordName()] = record
+		recordMap[recordKey(record)] = record
 	}
 
 	for _, record := range r2 {
-		if recordIsDifferent(record, recordMap[record.RecordName()]) {
-			t.Errorf("unexpected record set difference: %v vs %v", record, recordMap[record.RecordName()])
+		expected, found := recordMap[recordKey(record)]
+		if !found || recordIsDifferent(record, expected) {
+			t.Errorf("unexpected record set difference: %v vs %v", record, expected)
 		}
 	}
 }
+
+func TestSyncRecordsOnly(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:        "test",
+		DNSName:     "example.com.",
+		Description: "managed by dns-sync",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	if _, err := Sync(svc, zone, records, Options{RecordsOnly: true}); err == nil {
+		t.Errorf("expected an error for a missing zone")
+	}
+	if len(svc.ZoneMap) != 0 {
+		t.Errorf("expected no zone to be created: %v", svc.ZoneMap)
+	}
+
+	existing := Zone{Name: "example-com", DNSName: "EXAMPLE.com.", Description: "owned by the platform team"}
+	svc.ZoneMap = map[string]Zone{existing.Name: existing}
+	svc.RecordMap = map[string]FakeRecords{existing.Name: FakeRecords{}}
+	if _, err := Sync(svc, zone, records, Options{RecordsOnly: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(svc.ZoneMap) != 1 || !zonesEqual(existing, svc.ZoneMap[existing.Name]) {
+		t.Errorf("expected the zone to be left alone: %v", svc.ZoneMap)
+	}
+	recordsOut, err := svc.Records(existing)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectRecordSetsEqual(records, recordsOut, t)
+}
+
+func TestCheck(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{
+				Name: "www.example.com.",
+				TTL:  25,
+			},
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+
+	// A missing zone is drift, and isn't created.
+	plan, err := Check(svc, zone, records, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 1 || len(svc.ZoneMap) != 0 {
+		t.Errorf("expected drift without changes, saw: %v, %v", plan, svc.ZoneMap)
+	}
+	// Even without records to create.
+	if plan, err = Check(svc, zone, []Record{}, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if drift := plan.Drift(); len(drift) != 1 || drift[0] != "create zone test (example.com.)" {
+		t.Errorf("expected the missing zone to be drift, saw: %v", drift)
+	}
+
+	if _, err := Sync(svc, zone, records, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if plan, err = Check(svc, zone, records, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("expected no drift, saw: %v", plan)
+	}
+
+	// Someone edits the record in the console.
+	edited := records[0].(AddressRecord)
+	edited.Addresses = []string{"5.6.7.8"}
+	svc.RecordMap[zone.Name][recordKey(edited)] = edited
+	if plan, err = Check(svc, zone, records, Options{Limits: Limits{MaxChangePercent: 1}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 1 {
+		t.Fatalf("expected drift, saw: %v", plan)
+	}
+	if diff := plan.Changes[0].Diff(); diff != "update A www.example.com.: [5.6.7.8] -> [1.2.3.4]" {
+		t.Errorf("unexpected diff: %s", diff)
+	}
+	if svc.RecordMap[zone.Name][recordKey(edited)].RRData()[0] != "5.6.7.8" {
+		t.Errorf("expected check to leave the record alone")
+	}
+}
@@ -0,0 +1,259 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Configs can declare variables in a top level 'variables' section and
+// reference them in any value as ${name}. References are replaced in the
+// parsed values, and a value that is just a reference takes the type of the
+// variable, so they work in names, addresses and TTLs alike. In values with a
+// reference, $$ is a literal $; other values are left alone.
+var variablePattern = regexp.MustCompile(`\$\$|\$\{([^}]*)\}`)
+
+// referencePlaceholder stands in for a reference while a config is parsed,
+// since ${name} isn't a valid plain scalar in a flow sequence or mapping.
+const referencePlaceholder = "\ue000%d\ue001"
+
+// A declared variable can be overridden by an environment variable with this
+// prefix followed by the upper case variable name, with '-' and '.' replaced
+// by '_', e.g. DNS_SYNC_VAR_IP_BLOCK for ip-block.
+const variableEnvPrefix = "DNS_SYNC_VAR_"
+
+// maxVariableDepth limits how deeply variables may reference each other.
+const maxVariableDepth = 10
+
+func variableEnvName(name string) string {
+	return variableEnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// declaredVariables returns the variables section of a config.
+func declaredVariables(data []byte) (map[string]string, error) {
+	root, err := parseTemplate(data)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = marshalTemplate(root); err != nil {
+		return nil, err
+	}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	objMap := map[string]json.RawMessage{}
+	if err := json.Unmarshal(jsonData, &objMap); err != nil {
+		return nil, &FieldError{Message: "expected an object"}
+	}
+	result := map[string]string{}
+	msg, found := objMap["variables"]
+	if !found || string(msg) == "null" {
+		return result, nil
+	}
+	values := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(msg))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, &FieldError{Path: []interface{}{"variables"}, Message: "expected an object"}
+	}
+	for name, value := range values {
+		switch value := value.(type) {
+		case string, json.Number, bool:
+			result[name] = fmt.Sprint(value)
+		default:
+			return nil, &FieldError{Path: []interface{}{"variables", name}, Message: "expected a string, number or boolean"}
+		}
+	}
+	return result, nil
+}
+
+// resolveVariables applies environment and explicit overrides to the declared
+// variables and expands references between them.
+func resolveVariables(declared, overrides map[string]string) (map[string]string, error) {
+	result := map[string]string{}
+	for name, value := range declared {
+		if envValue, found := os.LookupEnv(variableEnvName(name)); found {
+			value = envValue
+		}
+		result[name] = value
+	}
+	names := []string{}
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, found := declared[name]; !found {
+			return nil, fmt.Errorf("can't set undeclared variable: %s", name)
+		}
+		result[name] = overrides[name]
+	}
+
+	names = []string{}
+	for name := range result {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for depth := 0; ; depth++ {
+		changed := false
+		for _, name := range names {
+			value := result[name]
+			expanded, err := expandString(value, result)
+			if err != nil {
+				return nil, fmt.Errorf("variable %s: %v", name, err)
+			}
+			if expanded != value {
+				result[name] = expanded
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		if depth == maxVariableDepth {
+			return nil, fmt.Errorf("variables reference each other too deeply")
+		}
+	}
+	for _, name := range names {
+		value := result[name]
+		if hasReference(value) {
+			return nil, fmt.Errorf("variable %s: references form a cycle", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("variable %s: values can't contain newlines", name)
+		}
+	}
+	return result, nil
+}
+
+func hasReference(value string) bool {
+	for _, match := range variablePattern.FindAllString(value, -1) {
+		if match != "$$" {
+			return true
+		}
+	}
+	return false
+}
+
+// expandString replaces references to variables in value. $$ is left alone so
+// that expansion can be repeated, and is unescaped by expandNode.
+func expandString(value string, variables map[string]string) (string, error) {
+	var err error
+	result := variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$$" {
+			return match
+		}
+		name := strings.TrimSpace(match[2 : len(match)-1])
+		replacement, found := variables[name]
+		if !found && err == nil {
+			err = fmt.Errorf("undefined variable: %s", name)
+		}
+		return replacement
+	})
+	return result, err
+}
+
+// configVariables returns the final value of every variable declared in a
+// config.
+func configVariables(data []byte, overrides map[string]string) (map[string]string, error) {
+	declared, err := declaredVariables(data)
+	if err != nil {
+		if fieldErr, ok := err.(*FieldError); ok {
+			fieldErr.Line = findLine(data, fieldErr.Path)
+		}
+		return nil, err
+	}
+	return resolveVariables(declared, overrides)
+}
+
+// expandVariables replaces every variable reference in the values of a
+// config, and returns it as YAML. Its layout may differ from data, so line
+// numbers have to be looked up in data.
+func expandVariables(data []byte, variables map[string]string) ([]byte, error) {
+	root, err := parseTemplate(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := expandNode(root, variables); err != nil {
+		return nil, err
+	}
+	return marshalTemplate(root)
+}
+
+// parseTemplate parses a config whose values may contain variable references.
+func parseTemplate(data []byte) (*yamlv3.Node, error) {
+	references := []string{}
+	protected := variablePattern.ReplaceAllStringFunc(string(data), func(match string) string {
+		if match == "$$" {
+			return match
+		}
+		references = append(references, match)
+		return fmt.Sprintf(referencePlaceholder, len(references)-1)
+	})
+	root := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal([]byte(protected), root); err != nil {
+		return nil, err
+	}
+	if len(references) > 0 {
+		placeholders := make([]string, 0, 2*len(references))
+		for ix, reference := range references {
+			placeholders = append(placeholders, fmt.Sprintf(referencePlaceholder, ix), reference)
+		}
+		replacer := strings.NewReplacer(placeholders...)
+		walkScalars(root, func(node *yamlv3.Node) error {
+			node.Value = replacer.Replace(node.Value)
+			return nil
+		})
+	}
+	return root, nil
+}
+
+// expandNode replaces the variable references in every scalar under node.
+func expandNode(node *yamlv3.Node, variables map[string]string) error {
+	return walkScalars(node, func(node *yamlv3.Node) error {
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		expanded, err := expandString(node.Value, variables)
+		if err != nil {
+			return &FieldError{Line: node.Line, Message: err.Error()}
+		}
+		node.Value = strings.Replace(expanded, "$$", "$", -1)
+		// Unquoted values are typed by their expanded value, so that e.g.
+		// 'ttl: ${ttl}' is a number.
+		if node.Style&(yamlv3.TaggedStyle|yamlv3.DoubleQuotedStyle|yamlv3.SingleQuotedStyle|yamlv3.LiteralStyle|yamlv3.FoldedStyle) == 0 {
+			node.Tag = ""
+		}
+		return nil
+	})
+}
+
+// walkScalars calls fn with every scalar under node. Aliases aren't followed,
+// so that each scalar is visited once.
+func walkScalars(node *yamlv3.Node, fn func(*yamlv3.Node) error) error {
+	if node.Kind == yamlv3.ScalarNode {
+		return fn(node)
+	}
+	for _, child := range node.Content {
+		if err := walkScalars(child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalTemplate returns a parsed config as YAML.
+func marshalTemplate(root *yamlv3.Node) ([]byte, error) {
+	if root.Kind == 0 {
+		return []byte{}, nil
+	}
+	return yamlv3.Marshal(root)
+}
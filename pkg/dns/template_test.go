@@ -0,0 +1,104 @@
+package dns
+
+import (
+	"testing"
+)
+
+const templateConfig = `variables:
+  env: staging
+  block: 10.1.0
+  ttl: 300
+  host: www.${env}
+zone:
+  name: ${env}
+  dnsName: ${env}.example.com.
+records:
+- kind: A
+  ttl: ${ttl}
+  name: ${host}.example.com.
+  addresses: [${block}.4]
+- kind: TXT
+  ttl: ${ ttl }
+  name: price.${env}.example.com.
+  text:
+  - costs $$5 in ${env}
+  - pa$$word
+`
+
+func TestParseConfigVariables(t *testing.T) {
+	config, err := ParseConfig([]byte(templateConfig), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Zone.DNSName != "staging.example.com." {
+		t.Errorf("unexpected zone: %v", config.Zone)
+	}
+	record := config.Records[0].(AddressRecord)
+	if record.Name != "www.staging.example.com." || record.TTL != 300 || record.Addresses[0] != "10.1.0.4" {
+		t.Errorf("unexpected record: %v", record)
+	}
+	if text := config.Records[1].(TXTRecord).Text; text[0] != "costs $5 in staging" || text[1] != "pa$$word" {
+		t.Errorf("unexpected text: %v", text)
+	}
+	if config.Variables["host"] != "www.staging" {
+		t.Errorf("unexpected variables: %v", config.Variables)
+	}
+}
+
+func TestParseConfigVariableOverrides(t *testing.T) {
+	t.Setenv("DNS_SYNC_VAR_BLOCK", "10.2.0")
+	t.Setenv("DNS_SYNC_VAR_TTL", "60")
+	config, err := ParseConfig([]byte(templateConfig), map[string]string{"env": "prod", "ttl": "120"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	record := config.Records[0].(AddressRecord)
+	if record.Name != "www.prod.example.com." || record.TTL != 120 || record.Addresses[0] != "10.2.0.4" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
+
+func TestParseConfigVariableErrors(t *testing.T) {
+	tests := []struct {
+		config    string
+		overrides map[string]string
+		expected  string
+	}{
+		{
+			config:   templateConfig + "- kind: A\n  ttl: 1\n  name: ${missing}.example.com.\n",
+			expected: "22: config: undefined variable: missing",
+		},
+		{
+			config:    templateConfig,
+			overrides: map[string]string{"enviroment": "prod"},
+			expected:  "can't set undeclared variable: enviroment",
+		},
+		{
+			config:    templateConfig,
+			overrides: map[string]string{"env": "two\nlines"},
+			expected:  "variable env: values can't contain newlines",
+		},
+		{
+			config:   "variables:\n  a: ${b}\n  b: ${a}\n",
+			expected: "variable a: references form a cycle",
+		},
+		{
+			config:   "variables:\n  a: ${a}\n",
+			expected: "variable a: references form a cycle",
+		},
+		{
+			config:   "variables:\n  a:\n    b: c\n",
+			expected: "2: variables.a: expected a string, number or boolean",
+		},
+	}
+	for ix, test := range tests {
+		_, err := ParseConfig([]byte(test.config), test.overrides)
+		if err == nil {
+			t.Errorf("[%d] expected an error", ix)
+			continue
+		}
+		if err.Error() != test.expected {
+			t.Errorf("[%d] expected '%s', saw '%s'", ix, test.expected, err.Error())
+		}
+	}
+}
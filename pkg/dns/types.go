@@ -1,8 +1,35 @@
 package dns
 
+import (
+	"fmt"
+)
+
 type Config struct {
-	Zone    Zone     `json:"zone" yaml:"zone"`
-	Records []Record `json:"records" yaml:"records"`
+	Zone      Zone              `json:"zone" yaml:"zone"`
+	Records   []Record          `json:"records" yaml:"records"`
+	Ignore    []IgnoreRule      `json:"ignore" yaml:"ignore"`
+	Variables map[string]string `json:"variables" yaml:"variables"`
+	// Include lists files, or directories of .yaml, .yml and .json files,
+	// whose records are merged into this config. Relative paths are relative
+	// to the including file.
+	Include []string `json:"include" yaml:"include"`
+	// Defaults are applied to records that don't set a field.
+	Defaults RecordDefaults `json:"defaults" yaml:"defaults"`
+
+	// missingTTL holds the indexes of records without a ttl that the
+	// config's own defaults don't cover either.
+	missingTTL []int
+	// files lists the files the config was loaded from, starting with the
+	// root config, and sources holds the location of each record.
+	files   []string
+	sources []recordSource
+	// dirs lists the included directories, which files can be added to.
+	dirs []string
+}
+
+type recordSource struct {
+	File string
+	Line int
 }
 
 type Zone struct {
@@ -10,6 +37,15 @@ type Zone struct {
 	DNSName     string   `json:"dnsName" yaml:"dnsName"`
 	Nameservers []string `json:"nameservers" yaml:"nameservers"`
 	Description string   `json:"description" yaml:"description"`
+	// SOA, if set, is applied to the SOA record the provider created with
+	// the zone.
+	SOA *SOA `json:"soa,omitempty" yaml:"soa,omitempty"`
+
+	// Managed is set for zones that dns-sync created, and Owner to the owner
+	// ID they were created with. Providers store them with the zone, e.g. as
+	// tags or labels. Only managed zones are pruned, unless listed.
+	Managed bool   `json:"-" yaml:"-"`
+	Owner   string `json:"-" yaml:"-"`
 }
 
 type Record interface {
@@ -17,11 +53,15 @@ type Record interface {
 	RecordName() string
 	TimeToLive() int64
 	RRData() []string
+	// CanonicalRRData returns the record data in the form used to compare
+	// records, so that ordering or formatting differences between the config
+	// and a provider don't show up as changes.
+	CanonicalRRData() []string
 }
 
 type BaseRecord struct {
 	Name string `json:"name" yaml:"name"`
-	TTL  int64  `json:"ttl" yaml:"ttl"`
+	TTL  TTL    `json:"ttl" yaml:"ttl"`
 	Kind string `json:"kind" yaml:"kind"`
 }
 
@@ -29,12 +69,21 @@ func (b BaseRecord) Type() string {
 	return b.Kind
 }
 
+// kindOr returns the record's kind in upper case, or kind if it isn't set,
+// e.g. for records built in code rather than loaded from a config.
+func (b BaseRecord) kindOr(kind string) string {
+	if len(b.Kind) > 0 {
+		return canonicalType(b.Kind)
+	}
+	return kind
+}
+
 func (b BaseRecord) RecordName() string {
 	return b.Name
 }
 
 func (b BaseRecord) TimeToLive() int64 {
-	return b.TTL
+	return int64(b.TTL)
 }
 
 type AddressRecord struct {
@@ -42,10 +91,18 @@ type AddressRecord struct {
 	Addresses  []string `json:"addresses" yaml:"addresses"`
 }
 
+func (a AddressRecord) Type() string {
+	return a.kindOr("A")
+}
+
 func (a AddressRecord) RRData() []string {
 	return a.Addresses
 }
 
+func (a AddressRecord) CanonicalRRData() []string {
+	return canonicalSet(a.Addresses, canonicalAddress)
+}
+
 var _ = Record(AddressRecord{})
 
 type CNameRecord struct {
@@ -53,10 +110,18 @@ type CNameRecord struct {
 	CanonicalName string `json:"canonicalName" yaml:"canonicalName"`
 }
 
+func (c CNameRecord) Type() string {
+	return c.kindOr("CNAME")
+}
+
 func (c CNameRecord) RRData() []string {
 	return []string{c.CanonicalName}
 }
 
+func (c CNameRecord) CanonicalRRData() []string {
+	return []string{canonicalName(c.CanonicalName)}
+}
+
 var _ = Record(CNameRecord{})
 
 type NSRecord struct {
@@ -64,8 +129,65 @@ type NSRecord struct {
 	Nameservers []string `json:"nameservers" yaml:"nameservers"`
 }
 
+func (n NSRecord) Type() string {
+	return n.kindOr("NS")
+}
+
 func (n NSRecord) RRData() []string {
 	return n.Nameservers
 }
 
+func (n NSRecord) CanonicalRRData() []string {
+	return canonicalSet(n.Nameservers, canonicalName)
+}
+
 var _ = Record(NSRecord{})
+
+type TXTRecord struct {
+	BaseRecord
+	Text []string `json:"text" yaml:"text"`
+}
+
+func (t TXTRecord) Type() string {
+	return t.kindOr("TXT")
+}
+
+func (t TXTRecord) RRData() []string {
+	return t.Text
+}
+
+func (t TXTRecord) CanonicalRRData() []string {
+	return canonicalSet(t.Text, canonicalText)
+}
+
+var _ = Record(TXTRecord{})
+
+// SOARecord is the start of authority record at the zone apex. It can't be
+// written in the records of a config; the zone's soa settings update it.
+type SOARecord struct {
+	BaseRecord
+	Nameserver string `json:"nameserver" yaml:"nameserver"`
+	// Email is in SOA form, e.g. hostmaster.example.com.
+	Email   string `json:"email" yaml:"email"`
+	Serial  int64  `json:"serial" yaml:"serial"`
+	Refresh TTL    `json:"refresh" yaml:"refresh"`
+	Retry   TTL    `json:"retry" yaml:"retry"`
+	Expire  TTL    `json:"expire" yaml:"expire"`
+	Minimum TTL    `json:"minimum" yaml:"minimum"`
+}
+
+func (s SOARecord) Type() string {
+	return s.kindOr("SOA")
+}
+
+func (s SOARecord) RRData() []string {
+	return []string{fmt.Sprintf("%s %s %d %d %d %d %d", s.Nameserver, s.Email, s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum)}
+}
+
+// CanonicalRRData leaves out the serial, which providers change on every
+// update.
+func (s SOARecord) CanonicalRRData() []string {
+	return []string{fmt.Sprintf("%s %s %d %d %d %d", canonicalName(s.Nameserver), canonicalName(s.Email), s.Refresh, s.Retry, s.Expire, s.Minimum)}
+}
+
+var _ = Record(SOARecord{})
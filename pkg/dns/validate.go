@@ -0,0 +1,303 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	maxTTL         = 2147483647
+	maxLabelLength = 63
+	maxNameLength  = 253
+)
+
+// Problem is a single mistake in a config.
+type Problem struct {
+	// Index is the index of the record in the config, or -1 for problems
+	// that aren't about a single record.
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	// File, Line and Path locate the problem, when known.
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+
+	path []interface{}
+}
+
+func (p Problem) String() string {
+	location := ""
+	if len(p.File) > 0 {
+		location = p.File + ":"
+	}
+	if p.Line > 0 {
+		location += fmt.Sprintf("%d:", p.Line)
+	}
+	if len(location) > 0 {
+		location += " "
+	}
+	switch {
+	case len(p.Path) > 0:
+		return fmt.Sprintf("%s%s: %s", location, p.Path, p.Message)
+	case p.Index < 0:
+		return fmt.Sprintf("%szone: %s", location, p.Message)
+	}
+	return fmt.Sprintf("%srecord %d (%s %s): %s", location, p.Index, p.Kind, p.Name, p.Message)
+}
+
+// ValidationError lists every problem found in a config.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (v *ValidationError) Error() string {
+	lines := make([]string, len(v.Problems))
+	for ix, problem := range v.Problems {
+		lines[ix] = problem.String()
+	}
+	return fmt.Sprintf("invalid config:\n  %s", strings.Join(lines, "\n  "))
+}
+
+// Validate checks the desired records for mistakes that a provider would
+// reject, or that would leave the zone broken. It returns a *ValidationError
+// listing every problem, or nil.
+func Validate(zone Zone, records []Record) error {
+	problems := []Problem{}
+	zoneProblem := func(format string, args ...interface{}) {
+		problems = append(problems, Problem{Index: -1, Name: zone.DNSName, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(zone.Name) == 0 {
+		zoneProblem("name is required")
+	}
+	if len(zone.DNSName) == 0 {
+		zoneProblem("dnsName is required")
+	} else if err := validateName(zone.DNSName); err != nil {
+		zoneProblem("dnsName %v", err)
+	} else if strings.Contains(zone.DNSName, "*") {
+		zoneProblem("dnsName can't be a wildcard")
+	}
+
+	if soa := zone.SOA; soa != nil {
+		if len(soa.Email) > 0 {
+			if err := validateName(soaEmail(soa.Email)); err != nil {
+				zoneProblem("soa email %v", err)
+			}
+		}
+		timers := []struct {
+			name  string
+			value TTL
+		}{
+			{"refresh", soa.Refresh},
+			{"retry", soa.Retry},
+			{"expire", soa.Expire},
+			{"negativeTTL", soa.NegativeTTL},
+			{"ttl", soa.TTL},
+		}
+		for _, timer := range timers {
+			if timer.value < 0 || timer.value > maxTTL {
+				zoneProblem("soa %s %d is out of range, it must be between 0 and %d", timer.name, timer.value, maxTTL)
+			}
+		}
+	}
+
+	apex := canonicalName(zone.DNSName)
+	seen := map[string]int{}
+	namesWithOther := map[string]bool{}
+	for ix, record := range records {
+		problem := func(format string, args ...interface{}) {
+			problems = append(problems, Problem{
+				Index:   ix,
+				Name:    record.RecordName(),
+				Kind:    record.Type(),
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+		name := canonicalName(record.RecordName())
+		if err := validateName(record.RecordName()); err != nil {
+			problem("name %v", err)
+		} else if err := validateWildcard(record.RecordName()); err != nil {
+			problem("name %v", err)
+		} else if name != apex && !strings.HasSuffix(name, "."+apex) {
+			problem("name is outside of zone %s", zone.DNSName)
+		}
+		if ttl := record.TimeToLive(); ttl < 1 || ttl > maxTTL {
+			problem("ttl %d is out of range, it must be between 1 and %d", ttl, maxTTL)
+		}
+		if first, found := seen[recordKey(record)]; found {
+			problem("duplicate of record %d", first)
+		} else {
+			seen[recordKey(record)] = ix
+		}
+		if len(record.RRData()) == 0 {
+			problem("has no data")
+		}
+
+		switch record.Type() {
+		case "A":
+			for _, address := range record.RRData() {
+				if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+					problem("'%s' is not a valid IPv4 address", address)
+				}
+			}
+		case "SOA":
+			problem("SOA records can't be listed, use the zone's soa settings")
+		case "CNAME", "NS":
+			for _, target := range record.RRData() {
+				if err := validateName(target); err != nil {
+					problem("target '%s' %v", target, err)
+				} else if strings.Contains(target, "*") {
+					problem("target '%s' can't be a wildcard", target)
+				}
+			}
+		}
+
+		if record.Type() != "CNAME" {
+			namesWithOther[name] = true
+		} else if name == apex {
+			problem("a CNAME can't be at the zone apex")
+		}
+	}
+	for ix, record := range records {
+		name := canonicalName(record.RecordName())
+		if record.Type() == "CNAME" && namesWithOther[name] {
+			problems = append(problems, Problem{
+				Index:   ix,
+				Name:    record.RecordName(),
+				Kind:    record.Type(),
+				Message: "a CNAME can't coexist with other records at the same name",
+			})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateWildcard checks that a name only uses '*' as its entire leftmost
+// label.
+func validateWildcard(name string) error {
+	for ix, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if !strings.Contains(label, "*") {
+			continue
+		}
+		if ix > 0 {
+			return fmt.Errorf("can only have a wildcard as the leftmost label")
+		}
+		if label != "*" {
+			return fmt.Errorf("wildcard must be a whole label, not '%s'", label)
+		}
+	}
+	return nil
+}
+
+// validateName checks that a DNS name converts to A-labels, and the length of
+// the converted name and of each of its labels.
+func validateName(name string) error {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	if len(name) == 0 {
+		return fmt.Errorf("is empty")
+	}
+	name, err := ToASCII(name)
+	if err != nil {
+		return err
+	}
+	if len(name) > maxNameLength {
+		return fmt.Errorf("is longer than %d characters", maxNameLength)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 {
+			return fmt.Errorf("has an empty label")
+		}
+		if len(label) > maxLabelLength {
+			return fmt.Errorf("has a label longer than %d characters: %s", maxLabelLength, label)
+		}
+	}
+	return nil
+}
+
+// ValidateFile loads a config file and checks it, and every file it
+// includes, against the config schema and the rules of Validate. It returns
+// every problem found, and only returns an error if the files can't be read.
+func ValidateFile(file string, overrides map[string]string) ([]Problem, error) {
+	config, err := LoadConfig(file, overrides)
+	if err != nil {
+		if fieldErr, ok := err.(*FieldError); ok {
+			return []Problem{{
+				Index:   -1,
+				File:    fieldErr.File,
+				Line:    fieldErr.Line,
+				Path:    formatPath(fieldErr.Path),
+				Message: fieldErr.Message,
+			}}, nil
+		}
+		return nil, err
+	}
+
+	problems := []Problem{}
+	schema := Schema()
+	for _, configFile := range config.files {
+		data, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		// The expanded copy loses the layout, so lines are found in data.
+		expanded, err := expandVariables(data, config.Variables)
+		if err != nil {
+			return nil, err
+		}
+		jsonData, err := yaml.YAMLToJSON(expanded)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(jsonData, &value); err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		fileSchema := schema
+		if configFile != file {
+			// Included files don't need a zone.
+			fileSchema = map[string]interface{}{}
+			for key, value := range schema {
+				fileSchema[key] = value
+			}
+			delete(fileSchema, "required")
+		}
+		for _, problem := range validateSchema(fileSchema, value, nil) {
+			problem.File = configFile
+			problem.Line = findLine(data, problem.path)
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	if err := Validate(config.Zone, config.Records); err != nil {
+		validationErr, ok := err.(*ValidationError)
+		if !ok {
+			return nil, err
+		}
+		for _, problem := range validationErr.Problems {
+			if problem.Index >= 0 && problem.Index < len(config.sources) {
+				problem.File = config.sources[problem.Index].File
+				problem.Line = config.sources[problem.Index].Line
+			} else {
+				problem.File = file
+			}
+			problems = append(problems, problem)
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,132 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	address := func(name string, ttl TTL, addresses ...string) Record {
+		return AddressRecord{
+			BaseRecord: BaseRecord{Name: name, TTL: ttl},
+			Addresses:  addresses,
+		}
+	}
+	alias := func(name, target string) Record {
+		return CNameRecord{
+			BaseRecord:    BaseRecord{Name: name, TTL: 25},
+			CanonicalName: target,
+		}
+	}
+	tests := []struct {
+		records  []Record
+		problems []string
+	}{
+		{
+			records: []Record{
+				address("www.example.com.", 25, "1.2.3.4"),
+				address("example.com.", 25, "1.2.3.4"),
+				alias("cname.example.com.", "www.example.com."),
+			},
+		},
+		{
+			records:  []Record{address("www.example.com.", 25, "1.2.3.4"), alias("www.example.com.", "other.com.")},
+			problems: []string{"can't coexist"},
+		},
+		{
+			records:  []Record{alias("example.com.", "other.com.")},
+			problems: []string{"zone apex"},
+		},
+		{
+			records:  []Record{address("www.example.org.", 25, "1.2.3.4"), address("badexample.com.", 25, "1.2.3.4")},
+			problems: []string{"outside of zone", "outside of zone"},
+		},
+		{
+			records:  []Record{address("www.example.com.", 0, "1.2.3.4"), address("ftp.example.com.", 2147483648, "1.2.3.4")},
+			problems: []string{"out of range", "out of range"},
+		},
+		{
+			records:  []Record{address("www.example.com.", 25, "1.2.3.4"), address("WWW.example.com", 25, "2.3.4.5")},
+			problems: []string{"duplicate of record 0"},
+		},
+		{
+			records:  []Record{address("www.example.com.", 25, "1.2.3.256", "2001:db8::1", "")},
+			problems: []string{"not a valid IPv4", "not a valid IPv4", "not a valid IPv4"},
+		},
+		{
+			records:  []Record{address(strings.Repeat("a", 64)+".example.com.", 25, "1.2.3.4"), address("a..example.com.", 25, "1.2.3.4")},
+			problems: []string{"label longer than 63", "empty label"},
+		},
+		{
+			records:  []Record{alias("cname.example.com.", strings.Repeat("a.", 130)+"com.")},
+			problems: []string{"longer than 253"},
+		},
+		{
+			records: []Record{
+				address("*.example.com.", 25, "1.2.3.4"),
+				alias("*.tenants.example.com.", "www.example.com."),
+			},
+		},
+		{
+			records: []Record{
+				address("www.*.example.com.", 25, "1.2.3.4"),
+				address("a*.example.com.", 25, "1.2.3.4"),
+				alias("cname.example.com.", "*.example.com."),
+			},
+			problems: []string{"leftmost label", "whole label", "can't be a wildcard"},
+		},
+	}
+	for ix, test := range tests {
+		err := Validate(zone, test.records)
+		if len(test.problems) == 0 {
+			if err != nil {
+				t.Errorf("[%d] unexpected error: %v", ix, err)
+			}
+			continue
+		}
+		validationErr, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("[%d] expected validation error, saw: %v", ix, err)
+			continue
+		}
+		if len(validationErr.Problems) != len(test.problems) {
+			t.Errorf("[%d] expected %d problems, saw: %v", ix, len(test.problems), validationErr)
+			continue
+		}
+		for jx, problem := range validationErr.Problems {
+			if !strings.Contains(problem.Message, test.problems[jx]) {
+				t.Errorf("[%d] expected '%s' in '%s'", ix, test.problems[jx], problem.Message)
+			}
+		}
+	}
+}
+
+func TestValidateZone(t *testing.T) {
+	if err := Validate(Zone{}, []Record{}); err == nil {
+		t.Errorf("expected an error for an empty zone")
+	}
+}
+
+func TestSyncValidatesBeforeProviderCalls(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{
+		Name:    "test",
+		DNSName: "example.com.",
+	}
+	records := []Record{
+		AddressRecord{
+			BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 25},
+			Addresses:  []string{"not-an-address"},
+		},
+	}
+	if _, err := Sync(svc, zone, records, Options{}); err == nil {
+		t.Errorf("expected a validation error")
+	}
+	if len(svc.ZoneMap) != 0 {
+		t.Errorf("expected no zones to be created: %v", svc.ZoneMap)
+	}
+}
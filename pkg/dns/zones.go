@@ -0,0 +1,186 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// ZoneNotEmptyError is returned when deleting a zone that still has records
+// other than the ones the provider created with it.
+type ZoneNotEmptyError struct {
+	Zone    Zone
+	Records []Record
+}
+
+func (z *ZoneNotEmptyError) Error() string {
+	return fmt.Sprintf("zone %s still has %d records, delete them first or delete them with the zone", z.Zone.Name, len(z.Records))
+}
+
+// isDefaultRecord returns true for the records a provider creates with a
+// zone, its SOA and apex NS records.
+func isDefaultRecord(zone Zone, record Record) bool {
+	return record.Type() == "SOA" || isApexNS(zone, record)
+}
+
+// FindZone returns the zone in the provider with the given name or DNS name,
+// or nil if there isn't one.
+func FindZone(service Service, name string) (*Zone, error) {
+	zones, err := service.Zones()
+	if err != nil {
+		return nil, err
+	}
+	for ix := range zones {
+		if zones[ix].Name == name || canonicalName(zones[ix].DNSName) == canonicalName(name) {
+			return &zones[ix], nil
+		}
+	}
+	return nil, nil
+}
+
+// managedZone returns zone marked as created by dns-sync for ownerID.
+func managedZone(zone Zone, ownerID string) Zone {
+	zone.Managed = true
+	zone.Owner = ownerID
+	return zone
+}
+
+// ownsZone returns true if dns-sync created zone with ownerID. Providers that
+// restrict the characters of labels store the owner ID as a ZoneLabel.
+func ownsZone(zone Zone, ownerID string) bool {
+	return zone.Managed && (zone.Owner == ownerID || zone.Owner == ZoneLabel(ownerID))
+}
+
+// ZoneLabel converts value to a valid label value for providers that restrict
+// them to 63 lower case letters, digits, '-' and '_'.
+func ZoneLabel(value string) string {
+	label := []rune{}
+	for _, r := range strings.ToLower(value) {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			r = '-'
+		}
+		label = append(label, r)
+	}
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return string(label)
+}
+
+// PruneOptions controls which zones ZonesToPrune returns.
+type PruneOptions struct {
+	// OwnerID selects the zones that dns-sync created with the same owner ID.
+	OwnerID string
+	// Zones lists further zones to prune by name or DNS name, even if
+	// dns-sync didn't create them.
+	Zones []string
+	// Limits applies to the records in the pruned zones, unless Force is set.
+	Limits Limits
+	Force  bool
+}
+
+// ZonesToPrune returns the zones in the provider that aren't in keep, matched
+// by name or DNS name, and that dns-sync created for options.OwnerID or that
+// options.Zones lists. Listing a zone in keep or one that doesn't exist is an
+// error. Unless options.Force is set, it returns a *LimitError if deleting the
+// records of the zones exceeds the limits, relative to the records of every
+// zone that is pruned or kept.
+func ZonesToPrune(service Service, keep []Zone, options PruneOptions) ([]Zone, error) {
+	zones, err := service.Zones()
+	if err != nil {
+		return nil, err
+	}
+	listed := map[string]bool{}
+	for _, name := range options.Zones {
+		listed[name] = false
+	}
+	result := []Zone{}
+	deletes, existing := 0, 0
+	for _, zone := range zones {
+		kept := false
+		for _, keepZone := range keep {
+			if (len(zone.Name) > 0 && zone.Name == keepZone.Name) ||
+				canonicalName(zone.DNSName) == canonicalName(keepZone.DNSName) {
+				kept = true
+				break
+			}
+		}
+		isListed := false
+		for name := range listed {
+			if zone.Name == name || canonicalName(zone.DNSName) == canonicalName(name) {
+				listed[name] = true
+				isListed = true
+			}
+		}
+		if kept && isListed {
+			return nil, fmt.Errorf("zone %s is in the config and can't be pruned", zone.Name)
+		}
+		// Zones that are kept count towards the limits, but other zones
+		// dns-sync didn't create aren't considered at all.
+		if !kept && !isListed && !ownsZone(zone, options.OwnerID) {
+			continue
+		}
+		if !options.Force {
+			records, err := service.Records(zone)
+			if err != nil {
+				return nil, err
+			}
+			count := len(nonDefaultRecords(zone, records))
+			existing += count
+			if !kept {
+				deletes += count
+			}
+		}
+		if !kept {
+			result = append(result, zone)
+		}
+	}
+	for _, name := range options.Zones {
+		if !listed[name] {
+			return nil, fmt.Errorf("zone %s doesn't exist", name)
+		}
+	}
+	if !options.Force {
+		if err := options.Limits.checkCounts(deletes, deletes, existing); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// nonDefaultRecords returns the records of zone other than its SOA and apex
+// NS records.
+func nonDefaultRecords(zone Zone, records []Record) []Record {
+	remaining := []Record{}
+	for _, record := range records {
+		if !isDefaultRecord(zone, record) {
+			remaining = append(remaining, record)
+		}
+	}
+	return remaining
+}
+
+// DeleteZone deletes a zone. If the zone has records other than its SOA and
+// apex NS records it returns a *ZoneNotEmptyError, unless deleteRecords is
+// set, in which case those records are deleted first.
+func DeleteZone(service Service, zone Zone, deleteRecords bool) error {
+	records, err := service.Records(zone)
+	if err != nil {
+		return err
+	}
+	remaining := nonDefaultRecords(zone, records)
+	if len(remaining) > 0 {
+		if !deleteRecords {
+			return &ZoneNotEmptyError{Zone: zone, Records: remaining}
+		}
+		for _, record := range remaining {
+			glog.V(2).Infof("Deleting record %s %s", record.Type(), record.RecordName())
+			if err := service.DeleteRecord(zone, record); err != nil {
+				return err
+			}
+		}
+	}
+	glog.Infof("Deleting zone %s.", zone.Name)
+	return service.DeleteZone(zone)
+}
@@ -0,0 +1,134 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestZonesToPrune(t *testing.T) {
+	address := AddressRecord{BaseRecord: BaseRecord{Name: "www.old.com.", TTL: 300}, Addresses: []string{"1.2.3.4"}}
+	svc := &FakeDNSService{
+		ZoneMap: map[string]Zone{
+			"keep":    Zone{Name: "keep", DNSName: "keep.com."},
+			"renamed": Zone{Name: "", DNSName: "renamed.com.", Managed: true, Owner: "team"},
+			"old":     Zone{Name: "old", DNSName: "old.com.", Managed: true, Owner: "team"},
+			"other":   Zone{Name: "other", DNSName: "other.com.", Managed: true, Owner: "other-team"},
+			"manual":  Zone{Name: "manual", DNSName: "manual.com."},
+			"listed":  Zone{Name: "listed", DNSName: "listed.com."},
+		},
+		RecordMap: map[string]FakeRecords{
+			"old": FakeRecords{recordKey(address): address},
+		},
+	}
+	keep := []Zone{
+		{Name: "keep", DNSName: "keep.com."},
+		{Name: "renamed", DNSName: "Renamed.com"},
+	}
+	zones, err := ZonesToPrune(svc, keep, PruneOptions{OwnerID: "team", Zones: []string{"listed.com"}})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	names := map[string]bool{}
+	for _, zone := range zones {
+		names[zone.Name] = true
+	}
+	if len(zones) != 2 || !names["old"] || !names["listed"] {
+		t.Errorf("expected only the team's old zone and the listed zone to be pruned, saw: %v", zones)
+	}
+
+	tests := []PruneOptions{
+		{OwnerID: "team", Zones: []string{"keep"}},
+		{OwnerID: "team", Zones: []string{"missing.com."}},
+		{OwnerID: "team", Limits: Limits{MaxDeletePercent: 50}},
+	}
+	for ix, options := range tests {
+		if _, err := ZonesToPrune(svc, keep, options); err == nil {
+			t.Errorf("[%d] expected an error", ix)
+		}
+	}
+	options := tests[2]
+	options.Force = true
+	if _, err := ZonesToPrune(svc, keep, options); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSyncMarksCreatedZones(t *testing.T) {
+	svc := &FakeDNSService{}
+	zone := Zone{Name: "test", DNSName: "example.com."}
+	if _, err := Sync(svc, zone, []Record{}, Options{OwnerID: "team"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created := svc.ZoneMap["test"]; !ownsZone(created, "team") {
+		t.Errorf("expected the zone to be marked as created for the team, saw: %+v", created)
+	}
+	zone.Description = "changed"
+	if _, err := Sync(svc, zone, []Record{}, Options{OwnerID: "team"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated := svc.ZoneMap["test"]; updated.Description != "changed" || !ownsZone(updated, "team") {
+		t.Errorf("expected the zone to keep its mark when updated, saw: %+v", updated)
+	}
+	if ownsZone(Zone{Name: "manual", DNSName: "manual.com."}, "") {
+		t.Errorf("expected zones dns-sync didn't create not to be owned")
+	}
+}
+
+func TestDeleteZone(t *testing.T) {
+	zone := Zone{Name: "test", DNSName: "example.com."}
+	soa := SOARecord{BaseRecord: BaseRecord{Name: "example.com.", TTL: 300}, Nameserver: "ns1.provider.net.", Email: "hostmaster.provider.net."}
+	ns := NSRecord{BaseRecord: BaseRecord{Name: "example.com.", TTL: 300}, Nameservers: []string{"ns1.provider.net."}}
+	address := AddressRecord{BaseRecord: BaseRecord{Name: "www.example.com.", TTL: 300}, Addresses: []string{"1.2.3.4"}}
+	newService := func(records ...Record) *FakeDNSService {
+		svc := &FakeDNSService{
+			ZoneMap:   map[string]Zone{zone.Name: zone},
+			RecordMap: map[string]FakeRecords{zone.Name: FakeRecords{}},
+		}
+		for _, record := range records {
+			svc.RecordMap[zone.Name][recordKey(record)] = record
+		}
+		return svc
+	}
+
+	svc := newService(soa, ns)
+	if err := DeleteZone(svc, zone, false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, found := svc.ZoneMap[zone.Name]; found {
+		t.Errorf("expected the zone to be deleted")
+	}
+
+	svc = newService(soa, ns, address)
+	err := DeleteZone(svc, zone, false)
+	notEmpty, ok := err.(*ZoneNotEmptyError)
+	if !ok || len(notEmpty.Records) != 1 {
+		t.Errorf("expected a zone not empty error, saw: %v", err)
+	}
+	if _, found := svc.ZoneMap[zone.Name]; !found {
+		t.Errorf("expected the zone to be kept")
+	}
+
+	if err := DeleteZone(svc, zone, true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, found := svc.ZoneMap[zone.Name]; found {
+		t.Errorf("expected the zone to be deleted")
+	}
+}
+
+func TestFindZone(t *testing.T) {
+	svc := &FakeDNSService{
+		ZoneMap: map[string]Zone{"test": Zone{Name: "test", DNSName: "example.com."}},
+	}
+	for _, name := range []string{"test", "example.com", "EXAMPLE.com."} {
+		zone, err := FindZone(svc, name)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if zone == nil || zone.Name != "test" {
+			t.Errorf("expected to find the zone by '%s', saw: %v", name, zone)
+		}
+	}
+	if zone, _ := FindZone(svc, "other"); zone != nil {
+		t.Errorf("unexpected zone: %v", zone)
+	}
+}